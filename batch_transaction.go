@@ -1,8 +1,12 @@
 package main
 
-import "github.com/alphatroya/ci-redmine-bindings/settings"
+import (
+	"context"
 
-func batchTransaction(rm DoneMarker, issues *IssuesContainer, settings *settings.Config, buildNumber int) *HookResponse {
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+func batchTransaction(ctx context.Context, rm DoneMarker, issues *IssuesContainer, settings *settings.Config, stamp *Stamp) *HookResponse {
 	type Result struct {
 		id  int
 		err error
@@ -10,7 +14,7 @@ func batchTransaction(rm DoneMarker, issues *IssuesContainer, settings *settings
 	ch := make(chan Result)
 	for _, issue := range issues.Issues {
 		go func(issue *Issue) {
-			err := rm.markAsDone(issue, settings, buildNumber)
+			err := rm.markAsDone(ctx, issue, settings, stamp)
 			ch <- Result{issue.ID, err}
 		}(issue)
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -19,7 +20,7 @@ func TestBatchSuccessTransaction(t *testing.T) {
 			{},
 		},
 	}
-	res := batchTransaction(m, il, s, 5)
+	res := batchTransaction(context.Background(), m, il, s, &Stamp{BuildNumber: 5})
 	if len(res.Success) != len(il.Issues) {
 		t.Errorf("Error during test expect: %d\nreceived: %d", len(il.Issues), len(res.Success))
 	}
@@ -37,7 +38,7 @@ func TestBatchFailTransaction(t *testing.T) {
 			{},
 		},
 	}
-	res := batchTransaction(m, il, s, 5)
+	res := batchTransaction(context.Background(), m, il, s, &Stamp{BuildNumber: 5})
 	if len(res.Failures) != len(il.Issues) {
 		t.Errorf("Error during test expect: %d\nreceived: %d", len(il.Issues), len(res.Failures))
 	}
@@ -47,7 +48,7 @@ type MockDoneMarker struct {
 	failable bool
 }
 
-func (m MockDoneMarker) markAsDone(issue *Issue, settings *settings.Config, buildNumber int) error {
+func (m MockDoneMarker) markAsDone(ctx context.Context, issue *Issue, settings *settings.Config, stamp *Stamp) error {
 	if m.failable {
 		return errors.New("Fail")
 	}
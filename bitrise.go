@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BitriseClient fetches build metadata missing in the webhook payload from Bitrise REST API
+type BitriseClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewBitriseClient creates client for Bitrise API base URL authorized with personal access token
+func NewBitriseClient(baseURL, token string, client *http.Client) *BitriseClient {
+	return &BitriseClient{baseURL: baseURL, token: token, client: client}
+}
+
+// BitriseBuild represents Bitrise build details
+type BitriseBuild struct {
+	Slug              string             `json:"slug"`
+	BuildNumber       int                `json:"build_number"`
+	Status            int                `json:"status"`
+	StatusText        string             `json:"status_text"`
+	Branch            string             `json:"branch"`
+	Tag               string             `json:"tag"`
+	CommitHash        string             `json:"commit_hash"`
+	CommitMessage     string             `json:"commit_message"`
+	TriggeredWorkflow string             `json:"triggered_workflow"`
+	TriggeredBy       string             `json:"triggered_by"`
+	TriggeredAt       time.Time          `json:"triggered_at,omitzero"`
+	FinishedAt        time.Time          `json:"finished_at,omitzero"`
+	URL               string             `json:"url"`
+	Artifacts         []*BitriseArtifact `json:"artifacts"`
+}
+
+// BitriseArtifact represents file produced by Bitrise build
+type BitriseArtifact struct {
+	Slug                 string `json:"slug"`
+	Title                string `json:"title"`
+	ArtifactType         string `json:"artifact_type"`
+	FileSizeBytes        int64  `json:"file_size_bytes"`
+	IsPublicPageEnabled  bool   `json:"is_public_page_enabled"`
+	PublicInstallPageURL string `json:"public_install_page_url"`
+	ExpiringDownloadURL  string `json:"expiring_download_url"`
+	// ArtifactMeta is set for app packages only
+	ArtifactMeta *BitriseArtifactMeta `json:"artifact_meta,omitempty"`
+}
+
+// BitriseArtifactMeta holds app metadata Bitrise extracts from ipa and apk artifacts
+type BitriseArtifactMeta struct {
+	AppInfo struct {
+		// Version is set for iOS apps, VersionName for Android ones
+		Version     string `json:"version,omitempty"`
+		VersionName string `json:"version_name,omitempty"`
+	} `json:"app_info"`
+}
+
+// InstallPageURL returns the first public install page among build artifacts
+func (b *BitriseBuild) InstallPageURL() string {
+	for _, artifact := range b.Artifacts {
+		if artifact.PublicInstallPageURL != "" {
+			return artifact.PublicInstallPageURL
+		}
+	}
+	return ""
+}
+
+// AppVersion returns app version of the first build artifact with app metadata
+func (b *BitriseBuild) AppVersion() string {
+	for _, artifact := range b.Artifacts {
+		if artifact.ArtifactMeta == nil {
+			continue
+		}
+		if info := artifact.ArtifactMeta.AppInfo; info.VersionName != "" {
+			return info.VersionName
+		} else if info.Version != "" {
+			return info.Version
+		}
+	}
+	return ""
+}
+
+// BuildDetails fetches build with its artifacts
+func (c *BitriseClient) BuildDetails(ctx context.Context, appSlug, buildSlug string) (*BitriseBuild, error) {
+	build, err := c.Build(ctx, appSlug, buildSlug)
+	if err != nil {
+		return nil, err
+	}
+	if build.Artifacts, err = c.Artifacts(ctx, appSlug, buildSlug); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// Build fetches build details
+func (c *BitriseClient) Build(ctx context.Context, appSlug, buildSlug string) (*BitriseBuild, error) {
+	var result struct {
+		Data *BitriseBuild `json:"data"`
+	}
+	if err := c.get(ctx, c.buildPath(appSlug, buildSlug), &result); err != nil {
+		return nil, fmt.Errorf("Build: %w", err)
+	}
+	if result.Data == nil {
+		return nil, fmt.Errorf("Build: build %s not found in response", buildSlug)
+	}
+	result.Data.URL = "https://app.bitrise.io/build/" + url.PathEscape(result.Data.Slug)
+	return result.Data, nil
+}
+
+// Artifacts fetches build artifacts with download and install page URLs,
+// list endpoint doesn't return them so every artifact is requested separately
+func (c *BitriseClient) Artifacts(ctx context.Context, appSlug, buildSlug string) ([]*BitriseArtifact, error) {
+	var artifacts []*BitriseArtifact
+	next := ""
+	for {
+		var page struct {
+			Data   []*BitriseArtifact `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		path := c.buildPath(appSlug, buildSlug) + "/artifacts"
+		if next != "" {
+			path += "?next=" + url.QueryEscape(next)
+		}
+		if err := c.get(ctx, path, &page); err != nil {
+			return nil, fmt.Errorf("Artifacts: %w", err)
+		}
+		artifacts = append(artifacts, page.Data...)
+		if next = page.Paging.Next; next == "" {
+			break
+		}
+	}
+
+	for i, artifact := range artifacts {
+		var result struct {
+			Data *BitriseArtifact `json:"data"`
+		}
+		if err := c.get(ctx, c.buildPath(appSlug, buildSlug)+"/artifacts/"+url.PathEscape(artifact.Slug), &result); err != nil {
+			return nil, fmt.Errorf("Artifacts: %w", err)
+		}
+		if result.Data != nil {
+			artifacts[i] = result.Data
+		}
+	}
+	return artifacts, nil
+}
+
+func (c *BitriseClient) buildPath(appSlug, buildSlug string) string {
+	return "/apps/" + url.PathEscape(appSlug) + "/builds/" + url.PathEscape(buildSlug)
+}
+
+func (c *BitriseClient) get(ctx context.Context, path string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", c.token)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("received wrong status code %d", response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newFakeBitrise serves build "slug" of app "app" with two pages of artifacts
+func newFakeBitrise(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/app/builds/slug", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"slug":"slug","build_number":512,"status":1,"status_text":"success","branch":"main","commit_hash":"abc","commit_message":"Fix crash","triggered_workflow":"internal","triggered_at":"2022-10-05T10:00:00Z"}}`))
+	})
+	mux.HandleFunc("GET /apps/app/builds/slug/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("next") == "" {
+			_, _ = w.Write([]byte(`{"data":[{"slug":"log","title":"build.log"}],"paging":{"next":"ipa"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"slug":"ipa","title":"app.ipa"}],"paging":{}}`))
+	})
+	mux.HandleFunc("GET /apps/app/builds/slug/artifacts/{artifact}", func(w http.ResponseWriter, r *http.Request) {
+		artifact := r.PathValue("artifact")
+		data := map[string]interface{}{"slug": artifact, "expiring_download_url": "https://download/" + artifact}
+		if artifact == "ipa" {
+			data["title"] = "app.ipa"
+			data["artifact_type"] = "ios-ipa"
+			data["is_public_page_enabled"] = true
+			data["public_install_page_url"] = "https://install/ipa"
+		} else {
+			data["title"] = "build.log"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestBitriseClientBuildDetails(t *testing.T) {
+	server := newFakeBitrise(t)
+	defer server.Close()
+
+	build, err := NewBitriseClient(server.URL, "token", http.DefaultClient).BuildDetails(context.Background(), "app", "slug")
+	if err != nil {
+		t.Fatalf("BuildDetails failed: %s", err)
+	}
+	expected := &BitriseBuild{
+		Slug:              "slug",
+		BuildNumber:       512,
+		Status:            1,
+		StatusText:        "success",
+		Branch:            "main",
+		CommitHash:        "abc",
+		CommitMessage:     "Fix crash",
+		TriggeredWorkflow: "internal",
+		TriggeredAt:       time.Date(2022, 10, 5, 10, 0, 0, 0, time.UTC),
+		URL:               "https://app.bitrise.io/build/slug",
+		Artifacts: []*BitriseArtifact{
+			{Slug: "log", Title: "build.log", ExpiringDownloadURL: "https://download/log"},
+			{Slug: "ipa", Title: "app.ipa", ArtifactType: "ios-ipa", IsPublicPageEnabled: true, PublicInstallPageURL: "https://install/ipa", ExpiringDownloadURL: "https://download/ipa"},
+		},
+	}
+	if diff := cmp.Diff(build, expected); diff != "" {
+		t.Errorf("Wrong build details, diff: %s", diff)
+	}
+	if build.InstallPageURL() != "https://install/ipa" {
+		t.Errorf("Wrong install page: %s", build.InstallPageURL())
+	}
+}
+
+func TestBitriseClientFailures(t *testing.T) {
+	server := newFakeBitrise(t)
+	defer server.Close()
+
+	cases := []struct {
+		token string
+		build string
+	}{
+		{"wrong", "slug"},
+		{"token", "missing"},
+	}
+	for _, tc := range cases {
+		if _, err := NewBitriseClient(server.URL, tc.token, http.DefaultClient).BuildDetails(context.Background(), "app", tc.build); err == nil {
+			t.Errorf("BuildDetails should fail for token %s and build %s", tc.token, tc.build)
+		}
+	}
+}
+
+func TestStamperLinksBitriseInstallPage(t *testing.T) {
+	bitrise := newFakeBitrise(t)
+	defer bitrise.Close()
+
+	var mu sync.Mutex
+	var notes []string
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var body struct {
+				Issue struct {
+					Notes string `json:"notes"`
+				} `json:"issue"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			notes = append(notes, body.Issue.Notes)
+			mu.Unlock()
+			return
+		}
+		_, _ = w.Write([]byte(`{"issues":[{"id":2}]}`))
+	}))
+	defer redmine.Close()
+
+	stamper := NewStamper(&settings.Config{Host: redmine.URL}, http.DefaultClient, newMemoryStorage())
+	stamper.SetBitrise(NewBitriseClient(bitrise.URL, "token", http.DefaultClient))
+	links, _ := NewBuildTemplate("build link", "{{.InstallPageURL}}", true)
+	stamper.SetBuildLinks(links)
+
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_slug":"slug", "app_slug":"app", "build_number":512}`))
+	req.Header.Set("REDMINE_PROJECT", "ios")
+	req.Header.Set("Bitrise-Event-Type", "build/finished")
+	stamper.ServeHTTP(httptest.NewRecorder(), req)
+
+	if diff := cmp.Diff(notes, []string{"Build 512: https://install/ipa"}); diff != "" {
+		t.Errorf("Issue should get install page from Bitrise API, diff: %s", diff)
+	}
+}
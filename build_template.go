@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// buildTemplateView is a data passed to build templates
+type buildTemplateView struct {
+	Project     string
+	BuildNumber int
+	BuildSlug   string
+	AppSlug     string
+	Workflow    string
+	Branch      string
+	Tag         string
+	// Version is the app version from Bitrise artifact metadata, when build details
+	// aren't available or have no app packages it is the tag without "v" prefix,
+	// e.g. 1.4.0 for v1.4.0
+	Version string
+	// Date is the build finish time
+	Date time.Time
+	// Build and InstallPageURL are set when build details are fetched from Bitrise API
+	Build          *BitriseBuild
+	InstallPageURL string
+}
+
+// BuildTemplate renders names and values from finished build data
+type BuildTemplate struct {
+	tmpl *texttemplate.Template
+}
+
+// NewBuildTemplate parses template and checks it against sample build data,
+// so unknown fields are reported on start instead of the first build.
+// Build details are in the sample only when details are fetched from Bitrise API,
+// so templates using Build fields fail without Bitrise API token
+func NewBuildTemplate(name, text string, details bool) (*BuildTemplate, error) {
+	tmpl, err := texttemplate.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("wrong %s template: %w", name, err)
+	}
+	sample := &buildTemplateView{Date: time.Now()}
+	if details {
+		sample.Build = new(BitriseBuild)
+	}
+	if err = tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("wrong %s template: %w", name, err)
+	}
+	return &BuildTemplate{tmpl: tmpl}, nil
+}
+
+// Render executes template for the build, empty result is an error
+func (t *BuildTemplate) Render(project string, payload *HookPayload) (string, error) {
+	view := &buildTemplateView{
+		Project:     project,
+		BuildNumber: payload.BuildNumber,
+		BuildSlug:   payload.BuildSlug,
+		AppSlug:     payload.AppSlug,
+		Workflow:    payload.BuildTriggeredWorkflow,
+		Branch:      payload.Git.SrcBranch,
+		Tag:         payload.Git.Tag,
+		Date:        time.Now(),
+	}
+	if build := payload.Details; build != nil {
+		view.Build = build
+		view.InstallPageURL = build.InstallPageURL()
+		if view.Branch == "" {
+			view.Branch = build.Branch
+		}
+		if view.Tag == "" {
+			view.Tag = build.Tag
+		}
+		if !build.FinishedAt.IsZero() {
+			view.Date = build.FinishedAt
+		}
+	}
+	if payload.Details != nil {
+		view.Version = payload.Details.AppVersion()
+	}
+	if view.Version == "" {
+		view.Version = strings.TrimPrefix(view.Tag, "v")
+	}
+
+	var buffer bytes.Buffer
+	if err := t.tmpl.Execute(&buffer, view); err != nil {
+		return "", fmt.Errorf("can't render %s: %w", t.tmpl.Name(), err)
+	}
+	result := strings.TrimSpace(buffer.String())
+	if result == "" {
+		return "", fmt.Errorf("%s template rendered empty value", t.tmpl.Name())
+	}
+	return result, nil
+}
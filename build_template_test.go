@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildTemplateRender(t *testing.T) {
+	payload := &HookPayload{BuildSlug: "slug", BuildNumber: 512, BuildTriggeredWorkflow: "internal"}
+	payload.Git.Tag = "v1.4.0"
+	detailed := &HookPayload{BuildSlug: "slug", BuildNumber: 513}
+	detailed.Details = &BitriseBuild{Branch: "main", Tag: "1.5.0", FinishedAt: time.Date(2022, 10, 5, 10, 0, 0, 0, time.UTC)}
+	packaged := &HookPayload{BuildNumber: 514}
+	packaged.Git.Tag = "v1.5.0"
+	packaged.Details = &BitriseBuild{Artifacts: []*BitriseArtifact{{Title: "log.txt"}, {ArtifactMeta: new(BitriseArtifactMeta)}}}
+	packaged.Details.Artifacts[1].ArtifactMeta.AppInfo.VersionName = "1.5.1"
+
+	cases := []struct {
+		template string
+		payload  *HookPayload
+		expected string
+	}{
+		{"https://app.bitrise.io/build/{{.BuildSlug}}", payload, "https://app.bitrise.io/build/slug"},
+		{"{{.Version}} ({{.BuildNumber}})", payload, "1.4.0 (512)"},
+		{"{{.Tag}} {{.Workflow}} {{.Project}}", payload, "v1.4.0 internal ios"},
+		{"{{.Version}} ({{.BuildNumber}}) {{.Branch}} {{.Date.Format \"2006-01-02\"}}", detailed, "1.5.0 (513) main 2022-10-05"},
+		{"{{.Version}} ({{.BuildNumber}})", packaged, "1.5.1 (514)"},
+	}
+
+	for _, tc := range cases {
+		tmpl, err := NewBuildTemplate("test", tc.template, true)
+		if err != nil {
+			t.Fatalf("Can't parse template %s: %s", tc.template, err)
+		}
+		received, err := tmpl.Render("ios", tc.payload)
+		if err != nil {
+			t.Fatalf("Render failed: %s", err)
+		}
+		if received != tc.expected {
+			t.Errorf("Wrong rendered value\nreceived: %s\nexpected: %s", received, tc.expected)
+		}
+	}
+
+	tmpl, _ := NewBuildTemplate("test", "{{.Tag}}", false)
+	if _, err := tmpl.Render("ios", &HookPayload{}); err == nil {
+		t.Error("Empty rendered value should fail")
+	}
+}
+
+func TestNewBuildTemplateValidation(t *testing.T) {
+	cases := []struct {
+		template   string
+		details    bool
+		shouldFail bool
+	}{
+		{"{{.BuildNumber}}", false, false},
+		{"{{.Build.CommitMessage}}", true, false},
+		{"{{.BuildNumber", true, true},
+		{"{{.Number}}", true, true},
+		{"{{.Build.Message}}", true, true},
+		{"{{.Build.CommitMessage}}", false, true},
+		{"{{with .Build}}{{.CommitMessage}}{{end}}", false, false},
+	}
+
+	for _, tc := range cases {
+		if _, err := NewBuildTemplate("test", tc.template, tc.details); (err != nil) != tc.shouldFail {
+			t.Errorf("Unexpected validation result for %s, error: %v", tc.template, err)
+		}
+	}
+}
+
+func TestMarkAsDoneWritesBuildLink(t *testing.T) {
+	type customField struct {
+		ID    int64  `json:"id"`
+		Value string `json:"value"`
+	}
+	type issueBody struct {
+		Notes        string         `json:"notes"`
+		CustomFields []*customField `json:"custom_fields"`
+	}
+
+	cases := []struct {
+		name        string
+		linkFieldID int64
+		expected    issueBody
+	}{
+		{
+			name:        "custom field",
+			linkFieldID: 2,
+			expected:    issueBody{CustomFields: []*customField{{1, "12"}, {2, "https://bitrise.io/build"}}},
+		},
+		{
+			name:     "journal note",
+			expected: issueBody{Notes: "Build 12: https://bitrise.io/build", CustomFields: []*customField{{1, "12"}}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received struct {
+				Issue issueBody `json:"issue"`
+			}
+			redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&received)
+			}))
+			defer redmine.Close()
+
+			config := &settings.Config{Host: redmine.URL, BuildFieldID: 1, LinkFieldID: tc.linkFieldID}
+			err := RedmineDoneMarker{client: http.DefaultClient}.markAsDone(context.Background(), &Issue{ID: 3}, config, &Stamp{BuildNumber: 12, Link: "https://bitrise.io/build"})
+			if err != nil {
+				t.Fatalf("markAsDone failed: %s", err)
+			}
+			if diff := cmp.Diff(received.Issue, tc.expected); diff != "" {
+				t.Errorf("Wrong issue update, diff: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog"
+)
+
+// DigestSchedule defines when project digest is sent, e.g. "daily 09:00" or "weekly mon 09:00"
+type DigestSchedule struct {
+	Weekly  bool
+	Weekday time.Weekday
+	Hour    int
+	Minute  int
+}
+
+var digestWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseDigestSchedule parses schedule in "daily HH:MM" or "weekly <weekday> HH:MM" format
+func ParseDigestSchedule(value string) (*DigestSchedule, error) {
+	fields := strings.Fields(strings.ToLower(value))
+	schedule := new(DigestSchedule)
+	var clock string
+	switch {
+	case len(fields) == 2 && fields[0] == "daily":
+		clock = fields[1]
+	case len(fields) == 3 && fields[0] == "weekly":
+		weekday, ok := digestWeekdays[fields[1]]
+		if !ok {
+			return nil, fmt.Errorf("ParseDigestSchedule: unknown weekday %q", fields[1])
+		}
+		schedule.Weekly = true
+		schedule.Weekday = weekday
+		clock = fields[2]
+	default:
+		return nil, fmt.Errorf("ParseDigestSchedule: wrong schedule %q, expected \"daily HH:MM\" or \"weekly mon HH:MM\"", value)
+	}
+
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return nil, fmt.Errorf("ParseDigestSchedule: wrong time %q: %w", clock, err)
+	}
+	schedule.Hour, schedule.Minute = parsed.Hour(), parsed.Minute()
+	return schedule, nil
+}
+
+// Start returns beginning of aggregation window ending at the digest time,
+// calendar days are used so DST changes don't shift the window
+func (s *DigestSchedule) Start(to time.Time) time.Time {
+	if s.Weekly {
+		return to.AddDate(0, 0, -7)
+	}
+	return to.AddDate(0, 0, -1)
+}
+
+// Next returns the first digest time strictly after the moment in its location
+func (s *DigestSchedule) Next(after time.Time) time.Time {
+	next := time.Date(after.Year(), after.Month(), after.Day(), s.Hour, s.Minute, 0, 0, after.Location())
+	for !next.After(after) || (s.Weekly && next.Weekday() != s.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Digest aggregates project builds finished in the period
+type Digest struct {
+	Project     string
+	RedmineHost string
+	From        time.Time
+	To          time.Time
+	// Weekly is set for digests covering a week
+	Weekly  bool
+	Builds  []*BuildRecord
+	Stamped []int
+	Failed  []int
+}
+
+// IssueURL returns link to the Redmine issue
+func (d *Digest) IssueURL(id int) string {
+	return fmt.Sprintf("%s/issues/%d", d.RedmineHost, id)
+}
+
+// BuildNumbers returns comma separated numbers of digest builds
+func (d *Digest) BuildNumbers() string {
+	numbers := make([]string, 0, len(d.Builds))
+	for _, build := range d.Builds {
+		numbers = append(numbers, strconv.Itoa(build.Number))
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// aggregateDigest collects builds finished in [from, to) period, builds go in finishing order
+func aggregateDigest(project string, builds []*BuildRecord, from, to time.Time) *Digest {
+	digest := &Digest{Project: project, From: from, To: to}
+	stamped := make(map[int]bool)
+	failed := make(map[int]bool)
+	for _, build := range builds {
+		if build.FinishedAt.IsZero() || build.FinishedAt.Before(from) || !build.FinishedAt.Before(to) {
+			continue
+		}
+		digest.Builds = append(digest.Builds, build)
+		for _, id := range build.Stamped {
+			stamped[id] = true
+		}
+		for _, id := range build.Failed {
+			failed[id] = true
+		}
+	}
+	sort.Slice(digest.Builds, func(i, j int) bool {
+		return digest.Builds[i].FinishedAt.Before(digest.Builds[j].FinishedAt)
+	})
+	// issue stamped by a later build is not a failure anymore
+	for id := range stamped {
+		delete(failed, id)
+	}
+	digest.Stamped = sortedIDs(stamped)
+	digest.Failed = sortedIDs(failed)
+	return digest
+}
+
+func sortedIDs(set map[int]bool) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// DigestNotifier is implemented by notifiers able to deliver periodic digests
+type DigestNotifier interface {
+	Name() string
+	NotifyDigest(ctx context.Context, digest *Digest) error
+}
+
+// DigestScheduler periodically sends project digests built from the history
+type DigestScheduler struct {
+	history *History
+	// storage keeps the last sent digest time, so digests fallen due during restart are sent
+	storage     Storage
+	redmineHost string
+	schedules   map[string]*DigestSchedule
+	notifiers   []DigestNotifier
+	now         func() time.Time
+	next        map[string]time.Time
+}
+
+// NewDigestScheduler creates scheduler for the project schedules in the location,
+// digests are delivered with every notifier supporting them
+func NewDigestScheduler(history *History, storage Storage, redmineHost string, schedules map[string]*DigestSchedule, location *time.Location, notifiers []Notifier) *DigestScheduler {
+	scheduler := &DigestScheduler{
+		history:     history,
+		storage:     storage,
+		redmineHost: redmineHost,
+		schedules:   schedules,
+		now: func() time.Time {
+			return time.Now().In(location)
+		},
+		next: make(map[string]time.Time),
+	}
+	for _, notifier := range notifiers {
+		if digestNotifier, ok := notifier.(DigestNotifier); ok {
+			scheduler.notifiers = append(scheduler.notifiers, digestNotifier)
+		}
+	}
+	return scheduler
+}
+
+// Run checks schedules every interval until context is cancelled
+func (d *DigestScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	d.tick(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.tick(ctx)
+		}
+	}
+}
+
+func digestSentKey(project string) string {
+	return "digest:sent:" + project
+}
+
+func (d *DigestScheduler) tick(ctx context.Context) {
+	now := d.now()
+	for project, schedule := range d.schedules {
+		next, ok := d.next[project]
+		if !ok {
+			next = d.firstDue(ctx, project, schedule, now)
+		}
+		if now.Before(next) {
+			d.next[project] = next
+			continue
+		}
+		// only the latest digest is sent when several fell due while the process was down
+		for due := schedule.Next(next); !due.After(now); due = schedule.Next(due) {
+			next = due
+		}
+		d.next[project] = schedule.Next(now)
+		d.send(ctx, project, schedule, next)
+	}
+}
+
+// firstDue returns the first digest time after the last sent one, without sent digests
+// the first digest time after now is used
+func (d *DigestScheduler) firstDue(ctx context.Context, project string, schedule *DigestSchedule, now time.Time) time.Time {
+	data, err := d.storage.Get(ctx, digestSentKey(project))
+	if errors.Is(err, ErrNotFound) {
+		return schedule.Next(now)
+	}
+	var sent time.Time
+	if err == nil {
+		sent, err = time.Parse(time.RFC3339, string(data))
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).
+			Error().
+			Err(err).
+			Str("r_project", project).
+			Msg("can't read last sent digest time")
+		return schedule.Next(now)
+	}
+	return schedule.Next(sent.In(now.Location()))
+}
+
+func (d *DigestScheduler) send(ctx context.Context, project string, schedule *DigestSchedule, to time.Time) {
+	logger := zerolog.Ctx(ctx).With().Str("r_project", project).Logger()
+	defer func() {
+		if err := d.storage.Set(ctx, digestSentKey(project), []byte(to.Format(time.RFC3339)), 0); err != nil {
+			logger.Error().
+				Err(err).
+				Msg("can't save last sent digest time")
+		}
+	}()
+	builds, err := d.history.Builds(ctx, project)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("can't read builds history for digest")
+		sentry.CaptureException(err)
+		return
+	}
+	digest := aggregateDigest(project, builds, schedule.Start(to), to)
+	digest.RedmineHost = d.redmineHost
+	digest.Weekly = schedule.Weekly
+	if len(digest.Builds) == 0 {
+		logger.Debug().
+			Msg("no builds for digest period, skipping")
+		return
+	}
+
+	for _, notifier := range d.notifiers {
+		if err := notifier.NotifyDigest(ctx, digest); err != nil {
+			logger.Error().
+				Err(err).
+				Str("notifier", notifier.Name()).
+				Msg("digest delivery failed")
+			sentry.CaptureException(err)
+			continue
+		}
+		logger.Info().
+			Str("notifier", notifier.Name()).
+			Int("builds", len(digest.Builds)).
+			Msg("digest delivered")
+	}
+}
+
+// ParseDigestSchedules parses per project digest schedules
+func ParseDigestSchedules(values map[string]string) (map[string]*DigestSchedule, error) {
+	schedules := make(map[string]*DigestSchedule, len(values))
+	for project, value := range values {
+		schedule, err := ParseDigestSchedule(value)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", project, err)
+		}
+		schedules[project] = schedule
+	}
+	return schedules, nil
+}
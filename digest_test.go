@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDigestSchedule(t *testing.T) {
+	cases := []struct {
+		value      string
+		expected   *DigestSchedule
+		shouldFail bool
+	}{
+		{value: "daily 09:00", expected: &DigestSchedule{Hour: 9}},
+		{value: "Weekly Fri 18:30", expected: &DigestSchedule{Weekly: true, Weekday: time.Friday, Hour: 18, Minute: 30}},
+		{value: "daily", shouldFail: true},
+		{value: "weekly 09:00", shouldFail: true},
+		{value: "weekly someday 09:00", shouldFail: true},
+		{value: "daily 25:00", shouldFail: true},
+		{value: "hourly 09:00", shouldFail: true},
+	}
+
+	for _, tc := range cases {
+		received, err := ParseDigestSchedule(tc.value)
+		if (err != nil) != tc.shouldFail {
+			t.Errorf("Unexpected parsing result for %q, error: %v", tc.value, err)
+			continue
+		}
+		if diff := cmp.Diff(received, tc.expected); diff != "" {
+			t.Errorf("Wrong schedule for %q, diff: %s", tc.value, diff)
+		}
+	}
+}
+
+func TestDigestScheduleNext(t *testing.T) {
+	// 2022-10-05 is Wednesday
+	now := time.Date(2022, 10, 5, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		schedule *DigestSchedule
+		expected time.Time
+	}{
+		{&DigestSchedule{Hour: 11}, time.Date(2022, 10, 5, 11, 0, 0, 0, time.UTC)},
+		{&DigestSchedule{Hour: 10}, time.Date(2022, 10, 6, 10, 0, 0, 0, time.UTC)},
+		{&DigestSchedule{Hour: 9}, time.Date(2022, 10, 6, 9, 0, 0, 0, time.UTC)},
+		{&DigestSchedule{Weekly: true, Weekday: time.Monday, Hour: 9}, time.Date(2022, 10, 10, 9, 0, 0, 0, time.UTC)},
+		{&DigestSchedule{Weekly: true, Weekday: time.Wednesday, Hour: 9}, time.Date(2022, 10, 12, 9, 0, 0, 0, time.UTC)},
+		{&DigestSchedule{Weekly: true, Weekday: time.Wednesday, Hour: 12}, time.Date(2022, 10, 5, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for i, tc := range cases {
+		if received := tc.schedule.Next(now); !received.Equal(tc.expected) {
+			t.Errorf("Test case #%d: wrong next time\nreceived: %s\nexpected: %s", i, received, tc.expected)
+		}
+	}
+}
+
+func TestAggregateDigest(t *testing.T) {
+	day := time.Date(2022, 10, 5, 0, 0, 0, 0, time.UTC)
+	builds := []*BuildRecord{
+		{Number: 3, FinishedAt: day.Add(20 * time.Hour), Stamped: []int{5}, Failed: []int{}},
+		{Number: 2, FinishedAt: day.Add(10 * time.Hour), Stamped: []int{3, 1}, Failed: []int{4, 5}},
+		{Number: 1, FinishedAt: day.Add(-time.Hour), Stamped: []int{7}},
+		{Number: 4, FinishedAt: day.Add(24 * time.Hour), Stamped: []int{8}},
+		{Number: 5, TriggeredAt: day.Add(time.Hour)},
+	}
+
+	digest := aggregateDigest("ios", builds, day, day.Add(24*time.Hour))
+	if digest.BuildNumbers() != "2, 3" {
+		t.Errorf("Wrong digest builds: %s", digest.BuildNumbers())
+	}
+	if diff := cmp.Diff(digest.Stamped, []int{1, 3, 5}); diff != "" {
+		t.Errorf("Wrong stamped issues, diff: %s", diff)
+	}
+	if diff := cmp.Diff(digest.Failed, []int{4}); diff != "" {
+		t.Errorf("Wrong failed issues, diff: %s", diff)
+	}
+}
+
+type mockDigestNotifier struct {
+	digests []*Digest
+}
+
+func (m *mockDigestNotifier) Name() string {
+	return "digest"
+}
+
+func (m *mockDigestNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	return nil
+}
+
+func (m *mockDigestNotifier) NotifyDigest(ctx context.Context, digest *Digest) error {
+	m.digests = append(m.digests, digest)
+	return nil
+}
+
+func TestDigestSchedulerSendsDueDigests(t *testing.T) {
+	ctx := context.Background()
+	history, clock := newTestHistory()
+	clock.now = time.Date(2022, 10, 5, 8, 0, 0, 0, time.UTC)
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "old", BuildNumber: 1}, "ios", &HookResponse{Success: []int{1}})
+	clock.Advance(2 * time.Hour)
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "new", BuildNumber: 2}, "ios", &HookResponse{Success: []int{2}})
+
+	notifier := new(mockDigestNotifier)
+	schedules := map[string]*DigestSchedule{"ios": {Hour: 9}, "android": {Hour: 9}}
+	scheduler := NewDigestScheduler(history, history.storage, "https://redmine.org", schedules, time.UTC, []Notifier{notifier, &mockNotifier{}})
+	scheduler.now = clock.Now
+
+	scheduler.tick(ctx)
+	if len(scheduler.notifiers) != 1 || len(notifier.digests) != 0 {
+		t.Fatalf("Nothing should be sent before schedule, received: %d digests", len(notifier.digests))
+	}
+
+	clock.Advance(22 * time.Hour)
+	scheduler.tick(ctx)
+	if len(notifier.digests) != 0 {
+		t.Fatalf("Nothing should be sent before schedule, received: %d digests", len(notifier.digests))
+	}
+	clock.Advance(time.Hour)
+	scheduler.tick(ctx)
+	scheduler.tick(ctx)
+	if len(notifier.digests) != 1 {
+		t.Fatalf("One digest should be sent, received: %d", len(notifier.digests))
+	}
+	digest := notifier.digests[0]
+	if digest.Project != "ios" || digest.BuildNumbers() != "2" || digest.RedmineHost != "https://redmine.org" {
+		t.Errorf("Wrong digest: %+v", digest)
+	}
+	if !digest.From.Equal(time.Date(2022, 10, 5, 9, 0, 0, 0, time.UTC)) || !digest.To.Equal(time.Date(2022, 10, 6, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong digest period: %s - %s", digest.From, digest.To)
+	}
+}
+
+func TestDigestScheduleStartFollowsCalendarDays(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Time zone database is unavailable: %s", err)
+	}
+	// DST ends on 2022-10-30, so the day before the digest lasts 25 hours
+	to := time.Date(2022, 10, 30, 9, 0, 0, 0, location)
+	cases := []struct {
+		schedule *DigestSchedule
+		expected time.Time
+	}{
+		{&DigestSchedule{Hour: 9}, time.Date(2022, 10, 29, 9, 0, 0, 0, location)},
+		{&DigestSchedule{Weekly: true, Weekday: time.Sunday, Hour: 9}, time.Date(2022, 10, 23, 9, 0, 0, 0, location)},
+	}
+	for _, tc := range cases {
+		if received := tc.schedule.Start(to); !received.Equal(tc.expected) {
+			t.Errorf("Wrong window start for %+v\nreceived: %s\nexpected: %s", tc.schedule, received, tc.expected)
+		}
+	}
+}
+
+func TestDigestSchedulerSendsDigestMissedDuringRestart(t *testing.T) {
+	ctx := context.Background()
+	history, clock := newTestHistory()
+	clock.now = time.Date(2022, 10, 5, 8, 0, 0, 0, time.UTC)
+	schedules := map[string]*DigestSchedule{"ios": {Hour: 9}}
+
+	notifier := new(mockDigestNotifier)
+	scheduler := NewDigestScheduler(history, history.storage, "https://redmine.org", schedules, time.UTC, []Notifier{notifier})
+	scheduler.now = clock.Now
+	scheduler.tick(ctx)
+	clock.Advance(time.Hour)
+	scheduler.tick(ctx)
+
+	clock.Advance(time.Hour)
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "slug", BuildNumber: 1}, "ios", &HookResponse{Success: []int{1}})
+
+	// the process is down during the next digest time and restarted later
+	clock.Advance(23*time.Hour + 30*time.Minute)
+	restarted := NewDigestScheduler(history, history.storage, "https://redmine.org", schedules, time.UTC, []Notifier{notifier})
+	restarted.now = clock.Now
+	restarted.tick(ctx)
+	restarted.tick(ctx)
+	if len(notifier.digests) != 1 {
+		t.Fatalf("Missed digest should be sent once after restart, received: %d", len(notifier.digests))
+	}
+	digest := notifier.digests[0]
+	if digest.BuildNumbers() != "1" || !digest.From.Equal(time.Date(2022, 10, 5, 9, 0, 0, 0, time.UTC)) || !digest.To.Equal(time.Date(2022, 10, 6, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong missed digest: %+v", digest)
+	}
+}
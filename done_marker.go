@@ -2,47 +2,95 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/alphatroya/ci-redmine-bindings/settings"
 )
 
+// Build field modes define how build number is written into the build custom field
+const (
+	BuildFieldModeReplace = "replace"
+	BuildFieldModeAppend  = "append"
+	BuildFieldModeList    = "list"
+)
+
+// Stamp describes values written into issues marked as done
+type Stamp struct {
+	BuildNumber int
+	// BuildValue replaces build number in the build custom field when set
+	BuildValue string
+	// BuildFieldMode is one of BuildFieldMode constants, replace is used when empty
+	BuildFieldMode string
+	// VersionIDs maps issue project ID to the target version ID,
+	// issues of projects missing in the map keep their version
+	VersionIDs map[int]int
+	// Link is a build download page written to the link custom field or journal note
+	Link string
+	// SwitchUser is Redmine login the issue is updated on behalf of
+	SwitchUser string
+}
+
 // DoneMarker defines interface for issue processing task
 type DoneMarker interface {
-	markAsDone(issue *Issue, settings *settings.Config, buildNumber int) error
+	markAsDone(ctx context.Context, issue *Issue, settings *settings.Config, stamp *Stamp) error
 }
 
 // RedmineDoneMarker move all issues to Done state with build number printing
-type RedmineDoneMarker struct{}
+type RedmineDoneMarker struct {
+	client *http.Client
+}
 
-func (r RedmineDoneMarker) markAsDone(issue *Issue, settings *settings.Config, buildNumber int) error {
+func (r RedmineDoneMarker) markAsDone(ctx context.Context, issue *Issue, settings *settings.Config, stamp *Stamp) error {
 	type PayloadCustomField struct {
-		ID    int64  `json:"id"`
-		Value string `json:"value"`
+		ID    int64       `json:"id"`
+		Value interface{} `json:"value"`
 	}
 
 	type PayloadIssue struct {
-		AssignedToID string                `json:"assigned_to_id"`
-		StatusID     string                `json:"status_id"`
-		CustomFields []*PayloadCustomField `json:"custom_fields"`
+		AssignedToID   string                `json:"assigned_to_id"`
+		StatusID       string                `json:"status_id"`
+		FixedVersionID int                   `json:"fixed_version_id,omitempty"`
+		Notes          string                `json:"notes,omitempty"`
+		CustomFields   []*PayloadCustomField `json:"custom_fields"`
 	}
 
 	type Payload struct {
 		Issue *PayloadIssue `json:"issue"`
 	}
 
+	if stamp.BuildFieldMode == BuildFieldModeAppend || stamp.BuildFieldMode == BuildFieldModeList {
+		// cached snapshot may be taken hours ago, so values written since then are fetched
+		fresh, err := issueByID(ctx, r.client, settings, issue.ID)
+		if err != nil {
+			return fmt.Errorf("can't fetch current build field value: %w", err)
+		}
+		issue.CustomFields = fresh.CustomFields
+	}
+
 	requestBody := Payload{
 		Issue: &PayloadIssue{
-			AssignedToID: fmt.Sprintf("%d", issue.Author.ID),
-			StatusID:     settings.DoneStatus,
+			AssignedToID:   fmt.Sprintf("%d", issue.Author.ID),
+			StatusID:       settings.DoneStatus,
+			FixedVersionID: stamp.VersionIDs[issue.Project.ID],
 			CustomFields: []*PayloadCustomField{
-				{settings.BuildFieldID, fmt.Sprintf("%d", buildNumber)},
+				{settings.BuildFieldID, buildFieldValue(issue, settings.BuildFieldID, stamp)},
 			},
 		},
 	}
 
+	if stamp.Link != "" {
+		if settings.LinkFieldID != 0 {
+			requestBody.Issue.CustomFields = append(requestBody.Issue.CustomFields, &PayloadCustomField{settings.LinkFieldID, stamp.Link})
+		} else {
+			requestBody.Issue.Notes = fmt.Sprintf("Build %d: %s", stamp.BuildNumber, stamp.Link)
+		}
+	}
+
 	body, err := json.Marshal(requestBody)
 	if err != nil {
 		return err
@@ -50,14 +98,13 @@ func (r RedmineDoneMarker) markAsDone(issue *Issue, settings *settings.Config, b
 
 	buffer := bytes.NewBuffer(body)
 
-	request, err := http.NewRequest("PUT", settings.Host+fmt.Sprintf("/issues/%d.json", issue.ID), buffer)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, settings.Host+fmt.Sprintf("/issues/%d.json", issue.ID), buffer)
 	if err != nil {
 		return err
 	}
-	request.Header.Set("X-Redmine-API-Key", settings.AuthToken)
-	request.Header.Set("Content-Type", "application/json")
+	setRedmineHeaders(request, settings, stamp.SwitchUser)
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := r.client.Do(request)
 	if err != nil {
 		return err
 	}
@@ -67,3 +114,56 @@ func (r RedmineDoneMarker) markAsDone(issue *Issue, settings *settings.Config, b
 	}
 	return nil
 }
+
+// buildFieldValue returns build custom field value, append and list modes keep
+// values already written into the field. Existing values are never split, so
+// rendered values containing commas stay intact
+func buildFieldValue(issue *Issue, fieldID int64, stamp *Stamp) interface{} {
+	number := stamp.BuildValue
+	if number == "" {
+		number = strconv.Itoa(stamp.BuildNumber)
+	}
+	if stamp.BuildFieldMode != BuildFieldModeAppend && stamp.BuildFieldMode != BuildFieldModeList {
+		return number
+	}
+
+	var values []string
+	if field := issue.CustomField(fieldID); field != nil {
+		for _, value := range field.Value {
+			if value = strings.TrimSpace(value); value != "" {
+				values = appendUnique(values, value)
+			}
+		}
+	}
+	if stamp.BuildFieldMode == BuildFieldModeList {
+		return appendUnique(values, number)
+	}
+
+	current := strings.Join(values, ", ")
+	switch {
+	case current == "":
+		return number
+	case containsListItem(current, number):
+		return current
+	default:
+		return current + ", " + number
+	}
+}
+
+// containsListItem reports whether comma separated list contains the item,
+// the item is matched as a whole so it may contain commas itself
+func containsListItem(list, item string) bool {
+	for offset := 0; ; {
+		i := strings.Index(list[offset:], item)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(item)
+		before := strings.TrimRight(list[:start], " ")
+		after := strings.TrimLeft(list[end:], " ")
+		if (before == "" || strings.HasSuffix(before, ",")) && (after == "" || strings.HasPrefix(after, ",")) {
+			return true
+		}
+		offset = start + 1
+	}
+}
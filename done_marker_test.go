@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildFieldValue(t *testing.T) {
+	cases := []struct {
+		mode     string
+		value    string
+		expected interface{}
+	}{
+		{BuildFieldModeReplace, `"10, 11"`, "12"},
+		{"", `"10, 11"`, "12"},
+		{BuildFieldModeAppend, `"10, 11"`, "10, 11, 12"},
+		{BuildFieldModeAppend, `"10,12"`, "10,12"},
+		{BuildFieldModeAppend, `"112"`, "112, 12"},
+		{BuildFieldModeAppend, `""`, "12"},
+		{BuildFieldModeAppend, `null`, "12"},
+		{BuildFieldModeList, `["10", "11"]`, []string{"10", "11", "12"}},
+		{BuildFieldModeList, `["12"]`, []string{"12"}},
+		{BuildFieldModeList, `[]`, []string{"12"}},
+	}
+
+	for _, tc := range cases {
+		issue := new(Issue)
+		data := `{"id":3,"custom_fields":[{"id":2,"value":"other"},{"id":1,"value":` + tc.value + `}]}`
+		if err := json.Unmarshal([]byte(data), issue); err != nil {
+			t.Fatalf("Can't decode issue %s: %s", data, err)
+		}
+		received := buildFieldValue(issue, 1, &Stamp{BuildNumber: 12, BuildFieldMode: tc.mode})
+		if diff := cmp.Diff(received, tc.expected); diff != "" {
+			t.Errorf("Wrong value for mode %q and field value %s, diff: %s", tc.mode, tc.value, diff)
+		}
+	}
+}
+
+func TestBuildFieldValueWithoutField(t *testing.T) {
+	received := buildFieldValue(&Issue{ID: 3}, 1, &Stamp{BuildNumber: 12, BuildFieldMode: BuildFieldModeList})
+	if diff := cmp.Diff(received, []string{"12"}); diff != "" {
+		t.Errorf("Wrong value for issue without field, diff: %s", diff)
+	}
+}
+
+func TestBuildFieldValueRenderedValue(t *testing.T) {
+	issue := &Issue{ID: 3, CustomFields: []*CustomField{{ID: 1, Value: CustomFieldValue{"1.3.0, build 400"}}}}
+	cases := map[string]interface{}{
+		BuildFieldModeReplace: "1.4.0, build 512",
+		BuildFieldModeAppend:  "1.3.0, build 400, 1.4.0, build 512",
+		BuildFieldModeList:    []string{"1.3.0, build 400", "1.4.0, build 512"},
+	}
+
+	for mode, expected := range cases {
+		received := buildFieldValue(issue, 1, &Stamp{BuildNumber: 512, BuildValue: "1.4.0, build 512", BuildFieldMode: mode})
+		if diff := cmp.Diff(received, expected); diff != "" {
+			t.Errorf("Wrong value for mode %s, diff: %s", mode, diff)
+		}
+	}
+}
+
+func TestMarkAsDoneAuthHeaders(t *testing.T) {
+	cases := []struct {
+		name       string
+		config     *settings.Config
+		switchUser string
+		expected   http.Header
+	}{
+		{
+			name:     "api key",
+			config:   &settings.Config{AuthToken: "key"},
+			expected: http.Header{"X-Redmine-Api-Key": {"key"}},
+		},
+		{
+			name:       "basic auth and impersonation",
+			config:     &settings.Config{AuthToken: "key", BasicUser: "proxy", BasicPassword: "secret"},
+			switchUser: "release-bot",
+			expected: http.Header{
+				"X-Redmine-Api-Key":     {"key"},
+				"Authorization":         {"Basic cHJveHk6c2VjcmV0"},
+				"X-Redmine-Switch-User": {"release-bot"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			received := make(http.Header)
+			redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, key := range []string{"X-Redmine-Api-Key", "Authorization", "X-Redmine-Switch-User"} {
+					if value := r.Header.Get(key); value != "" {
+						received.Set(key, value)
+					}
+				}
+			}))
+			defer redmine.Close()
+
+			tc.config.Host = redmine.URL
+			if err := (RedmineDoneMarker{client: http.DefaultClient}).markAsDone(context.Background(), &Issue{ID: 3}, tc.config, &Stamp{BuildNumber: 12, SwitchUser: tc.switchUser}); err != nil {
+				t.Fatalf("markAsDone failed: %s", err)
+			}
+			if diff := cmp.Diff(received, tc.expected); diff != "" {
+				t.Errorf("Wrong request headers, diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildFieldValueKeepsRenderedValueWithCommas(t *testing.T) {
+	issue := &Issue{ID: 3, CustomFields: []*CustomField{{ID: 1, Value: CustomFieldValue{"1.3.0, build 400, 1.4.0, build 512"}}}}
+	received := buildFieldValue(issue, 1, &Stamp{BuildNumber: 512, BuildValue: "1.4.0, build 512", BuildFieldMode: BuildFieldModeAppend})
+	if diff := cmp.Diff(received, "1.3.0, build 400, 1.4.0, build 512"); diff != "" {
+		t.Errorf("Already written value shouldn't be appended twice, diff: %s", diff)
+	}
+}
+
+func TestMarkAsDoneFetchesCurrentBuildField(t *testing.T) {
+	var received struct {
+		Issue struct {
+			CustomFields []struct {
+				Value []string `json:"value"`
+			} `json:"custom_fields"`
+		} `json:"issue"`
+	}
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"issue":{"id":3,"custom_fields":[{"id":1,"value":["10","11"]}]}}`))
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer redmine.Close()
+
+	stale := &Issue{ID: 3, CustomFields: []*CustomField{{ID: 1, Value: CustomFieldValue{"10"}}}}
+	config := &settings.Config{Host: redmine.URL, BuildFieldID: 1}
+	if err := (RedmineDoneMarker{client: http.DefaultClient}).markAsDone(context.Background(), stale, config, &Stamp{BuildNumber: 12, BuildFieldMode: BuildFieldModeList}); err != nil {
+		t.Fatalf("markAsDone failed: %s", err)
+	}
+	if len(received.Issue.CustomFields) != 1 {
+		t.Fatalf("Build field should be sent, received: %+v", received.Issue)
+	}
+	if diff := cmp.Diff(received.Issue.CustomFields[0].Value, []string{"10", "11", "12"}); diff != "" {
+		t.Errorf("Values written after the snapshot should be kept, diff: %s", diff)
+	}
+}
+
+func TestMarkAsDoneHonoursCancelledContext(t *testing.T) {
+	var requests int
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer redmine.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	config := &settings.Config{Host: redmine.URL, BuildFieldID: 1}
+	for _, mode := range []string{BuildFieldModeReplace, BuildFieldModeList} {
+		err := (RedmineDoneMarker{client: http.DefaultClient}).markAsDone(ctx, &Issue{ID: 3}, config, &Stamp{BuildNumber: 12, BuildFieldMode: mode})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("markAsDone should fail with cancelled context in %s mode, received: %v", mode, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Cancelled request shouldn't reach Redmine, received: %d requests", requests)
+	}
+}
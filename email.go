@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Email represents a message ready to be sent by mailer
+type Email struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers emails with a specific provider
+type Mailer interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+// EmailRouting configures recipients beyond the project summary list
+type EmailRouting struct {
+	// OnCall receives the summary when some issues failed to be stamped
+	OnCall []string
+	// AssigneeEmail resolves Redmine user email, personal emails are disabled if nil
+	AssigneeEmail func(ctx context.Context, userID int) (string, error)
+}
+
+// EmailNotifier renders build report emails and sends them with mailer
+type EmailNotifier struct {
+	mailer     Mailer
+	sender     string
+	recipients func(project string) []string
+	templates  *ReportTemplates
+	routing    EmailRouting
+}
+
+// NewEmailNotifier creates notifier sending report emails from sender to project recipients
+// and to additional recipients selected by routing rules
+func NewEmailNotifier(mailer Mailer, sender string, recipients func(project string) []string, templates *ReportTemplates, routing EmailRouting) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer, sender: sender, recipients: recipients, templates: templates, routing: routing}
+}
+
+// Name returns notifier name
+func (e *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Notify sends report email to the project recipients, on-call recipients and assignees,
+// failed assignee emails are reported with ErrPartialDelivery
+func (e *EmailNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	response := report.Response
+	if len(response.Success) == 0 && len(response.Failures) == 0 {
+		return ErrNothingToNotify
+	}
+
+	// personal emails are sent only after the summary, so a retried notification doesn't duplicate them
+	if err := e.notifySummary(ctx, report); err != nil {
+		return err
+	}
+	if e.routing.AssigneeEmail == nil {
+		return nil
+	}
+	if errs := e.notifyAssignees(ctx, report); len(errs) != 0 {
+		return fmt.Errorf("%w: %w", ErrPartialDelivery, errors.Join(errs...))
+	}
+	return nil
+}
+
+// NotifyDigest sends periodic digest to the project recipients
+func (e *EmailNotifier) NotifyDigest(ctx context.Context, digest *Digest) error {
+	recipients := e.recipients(digest.Project)
+	if len(recipients) == 0 {
+		return fmt.Errorf("EmailNotifier: no recipients for project %s", digest.Project)
+	}
+
+	rendered, err := e.templates.RenderDigest(digest)
+	if err != nil {
+		return err
+	}
+
+	return e.mailer.Send(ctx, &Email{
+		From:    e.sender,
+		To:      recipients,
+		Subject: rendered.Subject,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+}
+
+func (e *EmailNotifier) notifySummary(ctx context.Context, report *BuildReport) error {
+	recipients := e.recipients(report.Project)
+	if len(report.Response.Failures) != 0 {
+		recipients = appendUnique(recipients, e.routing.OnCall...)
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("EmailNotifier: no recipients for project %s", report.Project)
+	}
+
+	rendered, err := e.templates.Render(report)
+	if err != nil {
+		return err
+	}
+
+	return e.mailer.Send(ctx, &Email{
+		From:    e.sender,
+		To:      recipients,
+		Subject: rendered.Subject,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+}
+
+func (e *EmailNotifier) notifyAssignees(ctx context.Context, report *BuildReport) []error {
+	stamped := make(map[int]bool, len(report.Response.Success))
+	for _, id := range report.Response.Success {
+		stamped[id] = true
+	}
+
+	var assignees []int
+	assigned := make(map[int][]*Issue)
+	for _, issue := range report.Issues {
+		assigneeID := issue.AssignedTo.ID
+		if !stamped[issue.ID] || assigneeID == 0 {
+			continue
+		}
+		if _, ok := assigned[assigneeID]; !ok {
+			assignees = append(assignees, assigneeID)
+		}
+		assigned[assigneeID] = append(assigned[assigneeID], issue)
+	}
+
+	var errs []error
+	for _, assigneeID := range assignees {
+		issues := assigned[assigneeID]
+		email, err := e.routing.AssigneeEmail(ctx, assigneeID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rendered, err := e.templates.RenderPersonal(report, issues[0].AssignedTo.Name, issues)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = e.mailer.Send(ctx, &Email{
+			From:    e.sender,
+			To:      []string{email},
+			Subject: rendered.Subject,
+			Text:    rendered.Text,
+			HTML:    rendered.HTML,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("EmailNotifier: can't notify assignee #%d: %w", assigneeID, err))
+		}
+	}
+	return errs
+}
+
+func appendUnique(list []string, items ...string) []string {
+	result := append([]string{}, list...)
+	for _, item := range items {
+		found := false
+		for _, existing := range result {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMailer struct {
+	mu   sync.Mutex
+	sent []*Email
+}
+
+func (m *mockMailer) Send(ctx context.Context, email *Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+func TestEmailNotifierSendsRenderedReport(t *testing.T) {
+	templates, _ := LoadReportTemplates("", "", "")
+	mailer := new(mockMailer)
+	recipients := func(project string) []string {
+		return []string{project + "@example.com"}
+	}
+	notifier := NewEmailNotifier(mailer, "bot@example.com", recipients, templates, EmailRouting{})
+
+	if err := notifier.Notify(context.Background(), newTestReport()); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("One email should be sent, received: %d", len(mailer.sent))
+	}
+	email := mailer.sent[0]
+	if diff := cmp.Diff(email.To, []string{"app@example.com"}); diff != "" {
+		t.Errorf("Wrong recipients, diff: %s", diff)
+	}
+	if email.From != "bot@example.com" || email.Subject != "Redmine Hooks Results (App): build 512" || email.HTML == "" {
+		t.Errorf("Wrong email: %+v", email)
+	}
+}
+
+func TestEmailNotifierSkipsEmptyReport(t *testing.T) {
+	templates, _ := LoadReportTemplates("", "", "")
+	mailer := new(mockMailer)
+	notifier := NewEmailNotifier(mailer, "bot@example.com", func(string) []string { return nil }, templates, EmailRouting{})
+
+	report := newTestReport()
+	if err := notifier.Notify(context.Background(), report); err == nil {
+		t.Error("Notify should fail without recipients")
+	}
+	report.Response = NewResponse("")
+	if err := notifier.Notify(context.Background(), report); err == nil {
+		t.Error("Notify should fail on empty response")
+	}
+	if len(mailer.sent) != 0 {
+		t.Errorf("No emails should be sent, received: %d", len(mailer.sent))
+	}
+}
+
+func TestEmailNotifierRouting(t *testing.T) {
+	templates, _ := LoadReportTemplates("", "", "")
+	mailer := new(mockMailer)
+	routing := EmailRouting{
+		OnCall: []string{"oncall@example.com", "pm@example.com"},
+		AssigneeEmail: func(ctx context.Context, userID int) (string, error) {
+			return fmt.Sprintf("user%d@example.com", userID), nil
+		},
+	}
+	recipients := func(string) []string { return []string{"pm@example.com"} }
+	notifier := NewEmailNotifier(mailer, "bot@example.com", recipients, templates, routing)
+
+	report := newTestReport()
+	report.Issues[0].AssignedTo.ID = 7
+	report.Issues[1].AssignedTo.ID = 7
+	report.Issues[1].AssignedTo.Name = "John Doe"
+	report.Issues[2].AssignedTo.ID = 8
+	if err := notifier.Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+
+	var received [][]string
+	for _, email := range mailer.sent {
+		received = append(received, append([]string{email.Subject}, email.To...))
+	}
+	expected := [][]string{
+		{"Redmine Hooks Results (App): build 512", "pm@example.com", "oncall@example.com"},
+		{"Your tickets are in build 512 (App)", "user7@example.com"},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong sent emails, diff: %s", diff)
+	}
+	if !strings.Contains(mailer.sent[1].Text, "#1 Crash on <launch>") || !strings.Contains(mailer.sent[1].Text, "#2 Dark mode") {
+		t.Errorf("Personal email should list assignee issues, received:\n%s", mailer.sent[1].Text)
+	}
+}
+
+func TestEmailNotifierRoutingLookupFailure(t *testing.T) {
+	templates, _ := LoadReportTemplates("", "", "")
+	mailer := new(mockMailer)
+	routing := EmailRouting{
+		AssigneeEmail: func(ctx context.Context, userID int) (string, error) {
+			return "", errors.New("forbidden")
+		},
+	}
+	recipients := func(string) []string { return []string{"pm@example.com"} }
+	notifier := NewEmailNotifier(mailer, "bot@example.com", recipients, templates, routing)
+
+	report := newTestReport()
+	report.Issues[0].AssignedTo.ID = 7
+	if err := notifier.Notify(context.Background(), report); !errors.Is(err, ErrPartialDelivery) {
+		t.Errorf("Notify should report assignee lookup failure as partial delivery, received: %v", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("Summary should be sent despite lookup failure, received: %d emails", len(mailer.sent))
+	}
+}
+
+func TestEmailNotifierLookupFailureIsNotRetried(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	templates, _ := LoadReportTemplates("", "", "")
+	mailer := new(mockMailer)
+	routing := EmailRouting{
+		OnCall: []string{"oncall@example.com"},
+		AssigneeEmail: func(ctx context.Context, userID int) (string, error) {
+			return "", errors.New("forbidden")
+		},
+	}
+	recipients := func(string) []string { return []string{"pm@example.com"} }
+	stamper := NewStamper(&settings.Config{}, http.DefaultClient, nil, NewEmailNotifier(mailer, "bot@example.com", recipients, templates, routing))
+	outbox := NewOutbox(3, time.Millisecond)
+	go outbox.Run(ctx)
+	stamper.SetOutbox(outbox)
+
+	report := newTestReport()
+	report.Issues[0].AssignedTo.ID = 7
+	results := stamper.notify(ctx, report)
+	if len(results) != 1 || results[0].Status != NotificationPartial || results[0].Error == "" {
+		t.Errorf("Lookup failure should be reported as partial delivery, received: %+v", results[0])
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	if len(mailer.sent) != 1 {
+		t.Errorf("Summary should be sent exactly once, received: %d emails", len(mailer.sent))
+	}
+}
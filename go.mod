@@ -1,14 +1,35 @@
 module github.com/alphatroya/ci-redmine-bindings
 
-go 1.13
+go 1.24
 
 require (
-	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/getsentry/sentry-go v0.14.0
-	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/google/go-cmp v0.5.9
 	github.com/ilyakaznacheev/cleanenv v1.4.0
 	github.com/mailgun/mailgun-go/v4 v4.0.0
+	github.com/redis/go-redis/v9 v9.22.0
 	github.com/rs/zerolog v1.28.0
-	golang.org/x/net v0.23.0 // indirect
+	go.etcd.io/bbolt v1.3.11
+)
+
+require (
+	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-chi/chi v4.0.0+incompatible // indirect
+	github.com/joho/godotenv v1.4.0 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/kr/pretty v0.3.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.16 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rogpeppe/go-internal v1.8.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
 )
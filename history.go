@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BuildRecord represents a build processed by the hook
+type BuildRecord struct {
+	Slug        string    `json:"slug"`
+	Number      int       `json:"number"`
+	Project     string    `json:"project"`
+	Workflow    string    `json:"workflow"`
+	TriggeredAt time.Time `json:"triggered_at,omitzero"`
+	FinishedAt  time.Time `json:"finished_at,omitzero"`
+	Stamped     []int     `json:"stamped"`
+	Failed      []int     `json:"failed"`
+}
+
+// BuildRef points to a build which stamped an issue
+type BuildRef struct {
+	Number    int       `json:"number"`
+	Slug      string    `json:"slug"`
+	Timestamp time.Time `json:"timestamp"`
+	Project   string    `json:"project"`
+}
+
+// historyIndexLimit is the maximum number of builds kept in project and issue indexes
+const historyIndexLimit = 500
+
+// History persists processed builds in the storage. Project and issue indexes
+// are storage lists updated atomically, so several hook instances can share the storage
+type History struct {
+	storage Storage
+	// mu serializes read-modify-write updates of build records,
+	// events of the same build aren't expected concurrently
+	mu    sync.Mutex
+	now   func() time.Time
+	limit int
+}
+
+// NewHistory creates build history on top of storage
+func NewHistory(storage Storage) *History {
+	return &History{storage: storage, now: time.Now, limit: historyIndexLimit}
+}
+
+func historyBuildKey(slug string) string {
+	return "history:build:" + slug
+}
+
+func historyProjectKey(project string) string {
+	return "history:project:" + project
+}
+
+func historyIssueKey(issueID int) string {
+	return fmt.Sprintf("history:issue:%d", issueID)
+}
+
+// Triggered records build start
+func (h *History) Triggered(ctx context.Context, payload *HookPayload, project string) error {
+	return h.update(ctx, payload, project, func(record *BuildRecord) {
+		record.TriggeredAt = h.now()
+	})
+}
+
+// Finished records build completion with stamping results and indexes stamped issues
+func (h *History) Finished(ctx context.Context, payload *HookPayload, project string, response *HookResponse) error {
+	finishedAt := h.now()
+	err := h.update(ctx, payload, project, func(record *BuildRecord) {
+		record.FinishedAt = finishedAt
+		record.Stamped = response.Success
+		record.Failed = response.Failures
+	})
+	if err != nil {
+		return err
+	}
+
+	ref := BuildRef{Number: payload.BuildNumber, Slug: payload.BuildSlug, Timestamp: finishedAt, Project: project}
+	for _, issueID := range response.Success {
+		if err = h.addIssueBuild(ctx, issueID, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *History) addIssueBuild(ctx context.Context, issueID int, ref BuildRef) error {
+	refs, err := h.IssueBuilds(ctx, issueID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range refs {
+		if existing.Slug == ref.Slug {
+			return nil
+		}
+	}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	if _, err = h.storage.Append(ctx, historyIssueKey(issueID), data, h.limit); err != nil {
+		return fmt.Errorf("History: can't update issue #%d index: %w", issueID, err)
+	}
+	return nil
+}
+
+// IssueBuilds returns builds which stamped the issue, the earliest build goes first
+func (h *History) IssueBuilds(ctx context.Context, issueID int) ([]BuildRef, error) {
+	items, err := h.storage.List(ctx, historyIssueKey(issueID))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]BuildRef, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		var ref BuildRef
+		if err = json.Unmarshal(item, &ref); err != nil {
+			return nil, fmt.Errorf("History: can't decode issue #%d index: %w", issueID, err)
+		}
+		// the same build may be appended twice by concurrent instances
+		if !seen[ref.Slug] {
+			seen[ref.Slug] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+func (h *History) update(ctx context.Context, payload *HookPayload, project string, modify func(record *BuildRecord)) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	record, err := h.Build(ctx, payload.BuildSlug)
+	isNew := errors.Is(err, ErrNotFound)
+	if err != nil && !isNew {
+		return err
+	}
+	if isNew {
+		record = &BuildRecord{Slug: payload.BuildSlug, Stamped: []int{}, Failed: []int{}}
+	}
+	record.Number = payload.BuildNumber
+	record.Project = project
+	record.Workflow = payload.BuildTriggeredWorkflow
+	modify(record)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err = h.storage.Set(ctx, historyBuildKey(record.Slug), data, 0); err != nil {
+		return fmt.Errorf("History: can't save build %s: %w", record.Slug, err)
+	}
+	if !isNew {
+		return nil
+	}
+
+	dropped, err := h.storage.Append(ctx, historyProjectKey(project), []byte(record.Slug), h.limit)
+	if err != nil {
+		return fmt.Errorf("History: can't update project %s index: %w", project, err)
+	}
+	// builds dropped from the index can't be listed anymore, so their records are removed
+	for _, slug := range dropped {
+		if err = h.storage.Delete(ctx, historyBuildKey(string(slug))); err != nil {
+			return fmt.Errorf("History: can't remove build %s: %w", slug, err)
+		}
+	}
+	return nil
+}
+
+// Build returns stored build by slug or ErrNotFound
+func (h *History) Build(ctx context.Context, slug string) (*BuildRecord, error) {
+	data, err := h.storage.Get(ctx, historyBuildKey(slug))
+	if err != nil {
+		return nil, err
+	}
+	record := new(BuildRecord)
+	if err = json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("History: can't decode build %s: %w", slug, err)
+	}
+	return record, nil
+}
+
+// Builds returns stored builds of Redmine project kept in the index, the latest build goes first
+func (h *History) Builds(ctx context.Context, project string) ([]*BuildRecord, error) {
+	slugs, err := h.projectSlugs(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*BuildRecord, 0, len(slugs))
+	for i := len(slugs) - 1; i >= 0; i-- {
+		record, err := h.Build(ctx, slugs[i])
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (h *History) projectSlugs(ctx context.Context, project string) ([]string, error) {
+	items, err := h.storage.List(ctx, historyProjectKey(project))
+	if err != nil {
+		return nil, err
+	}
+	slugs := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if slug := string(item); !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/rs/zerolog"
+)
+
+// HistoryHandler serves stored build history
+type HistoryHandler struct {
+	history  *History
+	settings *settings.Config
+	client   *http.Client
+}
+
+// NewHistoryHandler creates API handlers on top of build history,
+// settings and client are used to fetch issue details from Redmine
+func NewHistoryHandler(history *History, settings *settings.Config, client *http.Client) *HistoryHandler {
+	return &HistoryHandler{history: history, settings: settings, client: client}
+}
+
+// Register adds history routes to the mux
+func (h *HistoryHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /builds", h.listBuilds)
+	mux.HandleFunc("GET /builds/{slug}", h.getBuild)
+	mux.HandleFunc("GET /builds/{slug}/release-notes", h.getReleaseNotes)
+	mux.HandleFunc("GET /issues/{id}/builds", h.getIssueBuilds)
+}
+
+func (h *HistoryHandler) listBuilds(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	if project == "" {
+		http.Error(w, "project query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	builds, err := h.history.Builds(r.Context(), project)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeJSON(w, r, builds)
+}
+
+func (h *HistoryHandler) getBuild(w http.ResponseWriter, r *http.Request) {
+	build, err := h.history.Build(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeJSON(w, r, build)
+}
+
+func (h *HistoryHandler) getReleaseNotes(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "html" {
+		http.Error(w, "format query parameter should be markdown or html", http.StatusBadRequest)
+		return
+	}
+
+	build, err := h.history.Build(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	issuesList, err := issuesByID(r.Context(), h.client, h.settings, build.Stamped)
+	if err != nil {
+		zerolog.Ctx(r.Context()).
+			Error().
+			Err(err).
+			Msg("can't fetch release notes issues")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	notes := NewReleaseNotes(build.Project, build.Number, h.settings.Host, issuesList.Issues, build.Stamped)
+	render, contentType := notes.Markdown, "text/markdown; charset=utf-8"
+	if format == "html" {
+		render, contentType = notes.HTML, "text/html; charset=utf-8"
+	}
+	body, err := render()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write([]byte(body))
+}
+
+func (h *HistoryHandler) getIssueBuilds(w http.ResponseWriter, r *http.Request) {
+	issueID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "issue id should be a number", http.StatusBadRequest)
+		return
+	}
+
+	builds, err := h.history.IssueBuilds(r.Context(), issueID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeJSON(w, r, builds)
+}
+
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	zerolog.Ctx(r.Context()).
+		Error().
+		Err(err).
+		Msg("history request failed")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		zerolog.Ctx(r.Context()).
+			Error().
+			Err(err).
+			Msg("can't encode response")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestHistory() (*History, *fakeClock) {
+	clock := &fakeClock{time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+	history := NewHistory(newMemoryStorage())
+	history.now = clock.Now
+	return history, clock
+}
+
+func TestHistoryRecordsBuildLifecycle(t *testing.T) {
+	ctx := context.Background()
+	history, clock := newTestHistory()
+	payload := &HookPayload{BuildSlug: "slug", BuildNumber: 12, BuildTriggeredWorkflow: "internal"}
+
+	if err := history.Triggered(ctx, payload, "ios"); err != nil {
+		t.Fatalf("Triggered failed: %s", err)
+	}
+	clock.Advance(time.Hour)
+	response := &HookResponse{Success: []int{1, 2}, Failures: []int{3}}
+	if err := history.Finished(ctx, payload, "ios", response); err != nil {
+		t.Fatalf("Finished failed: %s", err)
+	}
+
+	received, err := history.Build(ctx, "slug")
+	if err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	expected := &BuildRecord{
+		Slug:        "slug",
+		Number:      12,
+		Project:     "ios",
+		Workflow:    "internal",
+		TriggeredAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+		FinishedAt:  time.Date(2022, 10, 1, 13, 0, 0, 0, time.UTC),
+		Stamped:     []int{1, 2},
+		Failed:      []int{3},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong stored build, diff: %s", diff)
+	}
+}
+
+func TestHistoryBuildsByProject(t *testing.T) {
+	ctx := context.Background()
+	history, _ := newTestHistory()
+	for _, slug := range []string{"first", "second"} {
+		_ = history.Finished(ctx, &HookPayload{BuildSlug: slug}, "ios", NewResponse(""))
+		_ = history.Finished(ctx, &HookPayload{BuildSlug: slug}, "ios", NewResponse(""))
+	}
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "other"}, "android", NewResponse(""))
+
+	builds, err := history.Builds(ctx, "ios")
+	if err != nil {
+		t.Fatalf("Builds failed: %s", err)
+	}
+	var slugs []string
+	for _, build := range builds {
+		slugs = append(slugs, build.Slug)
+	}
+	if diff := cmp.Diff(slugs, []string{"second", "first"}); diff != "" {
+		t.Errorf("Wrong project builds, diff: %s", diff)
+	}
+
+	if _, err = history.Build(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Missing build should return ErrNotFound, received: %v", err)
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	history, _ := newTestHistory()
+	_ = history.Finished(context.Background(), &HookPayload{BuildSlug: "slug", BuildNumber: 5}, "ios", NewResponse(""))
+	mux := http.NewServeMux()
+	NewHistoryHandler(history, &settings.Config{}, http.DefaultClient).Register(mux)
+
+	cases := []struct {
+		url    string
+		status int
+	}{
+		{"/builds?project=ios", http.StatusOK},
+		{"/builds?project=android", http.StatusOK},
+		{"/builds", http.StatusBadRequest},
+		{"/builds/slug", http.StatusOK},
+		{"/builds/missing", http.StatusNotFound},
+		{"/issues/10/builds", http.StatusOK},
+		{"/issues/abc/builds", http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		mux.ServeHTTP(rw, req)
+		if rw.Code != tc.status {
+			t.Errorf("Wrong status code for %s, received: %d expected: %d", tc.url, rw.Code, tc.status)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/builds/slug", nil)
+	mux.ServeHTTP(rw, req)
+	build := new(BuildRecord)
+	if err := json.NewDecoder(rw.Body).Decode(build); err != nil {
+		t.Fatalf("Can't decode response: %s", err)
+	}
+	if build.Number != 5 || build.Project != "ios" {
+		t.Errorf("Wrong build in response: %+v", build)
+	}
+}
+
+func TestHistoryIndexesAreCapped(t *testing.T) {
+	ctx := context.Background()
+	history, _ := newTestHistory()
+	history.limit = 2
+	for _, slug := range []string{"first", "second", "third"} {
+		_ = history.Finished(ctx, &HookPayload{BuildSlug: slug}, "ios", &HookResponse{Success: []int{10}})
+	}
+
+	builds, _ := history.Builds(ctx, "ios")
+	var slugs []string
+	for _, build := range builds {
+		slugs = append(slugs, build.Slug)
+	}
+	if diff := cmp.Diff(slugs, []string{"third", "second"}); diff != "" {
+		t.Errorf("Project index should keep the latest builds, diff: %s", diff)
+	}
+	if _, err := history.Build(ctx, "first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Build dropped from the index should be removed, received: %v", err)
+	}
+
+	refs, _ := history.IssueBuilds(ctx, 10)
+	slugs = nil
+	for _, ref := range refs {
+		slugs = append(slugs, ref.Slug)
+	}
+	if diff := cmp.Diff(slugs, []string{"second", "third"}); diff != "" {
+		t.Errorf("Issue index should keep the latest builds, diff: %s", diff)
+	}
+}
+
+func TestHistoryIssueBuilds(t *testing.T) {
+	ctx := context.Background()
+	history, clock := newTestHistory()
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "first", BuildNumber: 1}, "ios", &HookResponse{Success: []int{10, 11}, Failures: []int{12}})
+	clock.Advance(time.Hour)
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "second", BuildNumber: 2}, "ios", &HookResponse{Success: []int{10}})
+	_ = history.Finished(ctx, &HookPayload{BuildSlug: "second", BuildNumber: 2}, "ios", &HookResponse{Success: []int{10}})
+
+	received, err := history.IssueBuilds(ctx, 10)
+	if err != nil {
+		t.Fatalf("IssueBuilds failed: %s", err)
+	}
+	expected := []BuildRef{
+		{Number: 1, Slug: "first", Timestamp: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC), Project: "ios"},
+		{Number: 2, Slug: "second", Timestamp: time.Date(2022, 10, 1, 13, 0, 0, 0, time.UTC), Project: "ios"},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong issue builds, diff: %s", diff)
+	}
+
+	for _, issueID := range []int{12, 13} {
+		received, _ = history.IssueBuilds(ctx, issueID)
+		if len(received) != 0 {
+			t.Errorf("Not stamped issue #%d should not be indexed, received: %+v", issueID, received)
+		}
+	}
+}
+
+func TestHistoryHandlerReleaseNotes(t *testing.T) {
+	var query string
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"issues":[{"id":3,"subject":"Crash on start","tracker":{"id":1,"name":"Bug"}},{"id":4,"subject":"Dark mode","tracker":{"id":2,"name":"Feature"}}]}`))
+	}))
+	defer redmine.Close()
+
+	history, _ := newTestHistory()
+	_ = history.Finished(context.Background(), &HookPayload{BuildSlug: "slug", BuildNumber: 5}, "ios", &HookResponse{Success: []int{4, 3}})
+	mux := http.NewServeMux()
+	NewHistoryHandler(history, &settings.Config{Host: redmine.URL}, http.DefaultClient).Register(mux)
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/builds/slug/release-notes", nil)
+	mux.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Wrong status code: %d", rw.Code)
+	}
+	if query != "status_id=*&limit=2&issue_id=4,3" {
+		t.Errorf("Wrong Redmine issues query: %s", query)
+	}
+	expected := "# ios build 5\n\n## Bug\n\n- Crash on start (#3)\n\n## Feature\n\n- Dark mode (#4)\n"
+	if diff := cmp.Diff(rw.Body.String(), expected); diff != "" {
+		t.Errorf("Wrong release notes, diff: %s", diff)
+	}
+
+	for url, status := range map[string]int{
+		"/builds/slug/release-notes?format=html": http.StatusOK,
+		"/builds/slug/release-notes?format=pdf":  http.StatusBadRequest,
+		"/builds/missing/release-notes":          http.StatusNotFound,
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		mux.ServeHTTP(rw, req)
+		if rw.Code != status {
+			t.Errorf("Wrong status code for %s, received: %d expected: %d", url, rw.Code, status)
+		}
+	}
+}
+
+func TestIssuesByIDFetchesChunks(t *testing.T) {
+	var limits []string
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		ids := strings.Split(r.URL.Query().Get("issue_id"), ",")
+		issues := make([]string, 0, len(ids))
+		for _, id := range ids {
+			issues = append(issues, `{"id":`+id+`}`)
+		}
+		_, _ = w.Write([]byte(`{"issues":[` + strings.Join(issues, ",") + `]}`))
+	}))
+	defer redmine.Close()
+
+	ids := make([]int, 0, 250)
+	for id := 1; id <= 250; id++ {
+		ids = append(ids, id)
+	}
+	received, err := issuesByID(context.Background(), http.DefaultClient, &settings.Config{Host: redmine.URL}, ids)
+	if err != nil {
+		t.Fatalf("issuesByID failed: %s", err)
+	}
+	if diff := cmp.Diff(issueIDs(received.Issues), ids); diff != "" {
+		t.Errorf("Every issue should be fetched, diff: %s", diff)
+	}
+	if diff := cmp.Diff(limits, []string{"100", "100", "50"}); diff != "" {
+		t.Errorf("IDs should be requested in chunks of Redmine page size, diff: %s", diff)
+	}
+}
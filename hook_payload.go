@@ -5,9 +5,17 @@ import "errors"
 // HookPayload represents webhook json payload sended from Bitrise
 type HookPayload struct {
 	BuildSlug              string `json:"build_slug"`
+	AppSlug                string `json:"app_slug"`
 	BuildNumber            int    `json:"build_number"`
 	BuildStatus            int    `json:"build_status"`
 	BuildTriggeredWorkflow string `json:"build_triggered_workflow"`
+	Git                    struct {
+		SrcBranch string `json:"src_branch"`
+		Tag       string `json:"tag"`
+	} `json:"git"`
+
+	// Details contains build metadata fetched from Bitrise API when it's configured
+	Details *BitriseBuild `json:"-"`
 }
 
 // ValidateInternal check out hook payload for only internal events
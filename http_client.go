@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+// newHTTPClient creates client with custom CA bundle, client certificate and proxy,
+// proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment is used when it isn't set
+func newHTTPClient(config settings.HTTP) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify} //nolint:gosec // opt-in for staging
+
+	if config.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("newHTTPClient: can't read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("newHTTPClient: no certificates found in CA bundle %s", config.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.ClientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("newHTTPClient: can't load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("newHTTPClient: wrong proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: transport}, nil
+}
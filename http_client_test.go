@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600); err != nil {
+		t.Fatalf("Can't write %s: %s", path, err)
+	}
+}
+
+// writeClientCertificate creates self-signed client certificate and key files
+func writeClientCertificate(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %s", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certPath, keyPath = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath
+}
+
+func TestHTTPClientTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	writePEM(t, caPath, "CERTIFICATE", server.Certificate().Raw)
+	certPath, keyPath := writeClientCertificate(t, dir)
+
+	cases := []struct {
+		name       string
+		config     settings.HTTP
+		status     int
+		shouldFail bool
+	}{
+		{name: "unknown CA", shouldFail: true},
+		{name: "CA bundle", config: settings.HTTP{CABundle: caPath}, status: http.StatusUnauthorized},
+		{name: "insecure", config: settings.HTTP{InsecureSkipVerify: true}, status: http.StatusUnauthorized},
+		{name: "client certificate", config: settings.HTTP{CABundle: caPath, ClientCert: certPath, ClientKey: keyPath}, status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := newHTTPClient(tc.config)
+			if err != nil {
+				t.Fatalf("Can't create client: %s", err)
+			}
+			response, err := client.Get(server.URL)
+			if (err != nil) != tc.shouldFail {
+				t.Fatalf("Unexpected request result, error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			defer response.Body.Close()
+			if response.StatusCode != tc.status {
+				t.Errorf("Wrong status code, received: %d expected: %d", response.StatusCode, tc.status)
+			}
+		})
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := newHTTPClient(settings.HTTP{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("Can't create client: %s", err)
+	}
+	response, err := client.Get("http://redmine.internal/issues.json")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	if requested != "http://redmine.internal/issues.json" {
+		t.Errorf("Request should go through proxy, proxy received: %q", requested)
+	}
+}
+
+func TestHTTPClientWrongConfig(t *testing.T) {
+	dir := t.TempDir()
+	emptyPath := filepath.Join(dir, "empty.pem")
+	_ = os.WriteFile(emptyPath, []byte("not a certificate"), 0o600)
+
+	cases := []settings.HTTP{
+		{CABundle: filepath.Join(dir, "missing.pem")},
+		{CABundle: emptyPath},
+		{ClientCert: emptyPath, ClientKey: emptyPath},
+		{ProxyURL: "://proxy"},
+	}
+	for _, config := range cases {
+		if _, err := newHTTPClient(config); err == nil {
+			t.Errorf("Client creation should fail for %+v", config)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IssueQuery selects ready to build issues of the Redmine projects
+type IssueQuery struct {
+	// Project is the primary project of the build used for history, versions and wiki pages
+	Project string
+	// Projects are all Redmine projects issues are selected from, the primary one goes first
+	Projects []string
+	// Filters are extra Redmine issues API filters, see ParseIssueFilters
+	Filters url.Values
+}
+
+// NewIssueQuery creates query for comma separated Redmine projects list, e.g. `ios,core`
+func NewIssueQuery(projects string, filters url.Values) (*IssueQuery, error) {
+	query := &IssueQuery{Filters: filters}
+	for _, project := range strings.Split(projects, ",") {
+		if project = strings.TrimSpace(project); project != "" {
+			query.Projects = appendUnique(query.Projects, project)
+		}
+	}
+	if len(query.Projects) == 0 {
+		return nil, errors.New("NewIssueQuery: Redmine projects list is empty")
+	}
+	query.Project = query.Projects[0]
+	return query, nil
+}
+
+var customFieldFilter = regexp.MustCompile(`^cf_\d+$`)
+
+// ParseIssueFilters parses URL query formatted issue filters,
+// e.g. `tracker_id=1|2&fixed_version_id=5&cf_3=iOS&assigned_to_id=7&subprojects=false`.
+// Supported keys are Redmine tracker_id, fixed_version_id, assigned_to_id and cf_<id> filters,
+// subprojects=true includes issues of subprojects and subprojects=false excludes them,
+// server default is used otherwise
+func ParseIssueFilters(raw string) (url.Values, error) {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIssueFilters: %w", err)
+	}
+
+	filters := make(url.Values, len(values))
+	for key, value := range values {
+		if len(value) != 1 || value[0] == "" {
+			return nil, fmt.Errorf("ParseIssueFilters: filter %s should have a single value", key)
+		}
+		switch {
+		case key == "tracker_id", key == "fixed_version_id", key == "assigned_to_id", customFieldFilter.MatchString(key):
+			filters.Set(key, value[0])
+		case key == "subprojects":
+			include, err := strconv.ParseBool(value[0])
+			if err != nil {
+				return nil, fmt.Errorf("ParseIssueFilters: subprojects should be true or false: %w", err)
+			}
+			if include {
+				filters.Set("subproject_id", "*")
+			} else {
+				filters.Set("subproject_id", "!*")
+			}
+		default:
+			return nil, fmt.Errorf("ParseIssueFilters: unsupported filter %s", key)
+		}
+	}
+	return filters, nil
+}
+
+// mergeIssueFilters returns defaults with keys replaced by overrides
+func mergeIssueFilters(defaults, overrides url.Values) url.Values {
+	merged := make(url.Values, len(defaults)+len(overrides))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
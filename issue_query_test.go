@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseIssueFilters(t *testing.T) {
+	cases := []struct {
+		raw        string
+		expected   url.Values
+		shouldFail bool
+	}{
+		{raw: "", expected: url.Values{}},
+		{
+			raw: "tracker_id=1|2&fixed_version_id=5&cf_3=iOS&assigned_to_id=me&subprojects=false",
+			expected: url.Values{
+				"tracker_id":       {"1|2"},
+				"fixed_version_id": {"5"},
+				"cf_3":             {"iOS"},
+				"assigned_to_id":   {"me"},
+				"subproject_id":    {"!*"},
+			},
+		},
+		{raw: "subprojects=true", expected: url.Values{"subproject_id": {"*"}}},
+		{raw: "subprojects=maybe", shouldFail: true},
+		{raw: "status_id=5", shouldFail: true},
+		{raw: "cf_abc=1", shouldFail: true},
+		{raw: "tracker_id=1&tracker_id=2", shouldFail: true},
+		{raw: "tracker_id=", shouldFail: true},
+		{raw: "tracker_id=%zz", shouldFail: true},
+	}
+
+	for _, tc := range cases {
+		received, err := ParseIssueFilters(tc.raw)
+		if (err != nil) != tc.shouldFail {
+			t.Errorf("Unexpected parsing result for %q, error: %v", tc.raw, err)
+			continue
+		}
+		if diff := cmp.Diff(received, tc.expected); diff != "" {
+			t.Errorf("Wrong filters for %q, diff: %s", tc.raw, diff)
+		}
+	}
+}
+
+func TestStamperAppliesIssueFilters(t *testing.T) {
+	var query url.Values
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"issues":[]}`))
+	}))
+	defer redmine.Close()
+
+	stamper := NewStamper(&settings.Config{Host: redmine.URL, RtbStatus: "2", CacheTTL: time.Hour}, http.DefaultClient, newMemoryStorage())
+	stamper.SetIssueFilters(url.Values{"tracker_id": {"1"}, "cf_3": {"iOS"}})
+
+	cases := []struct {
+		header   string
+		status   int
+		expected url.Values
+	}{
+		{
+			status:   http.StatusOK,
+			expected: url.Values{"status_id": {"2"}, "project_id": {"11"}, "tracker_id": {"1"}, "cf_3": {"iOS"}, "limit": {"100"}, "offset": {"0"}},
+		},
+		{
+			header:   "cf_3=Android&subprojects=false",
+			status:   http.StatusOK,
+			expected: url.Values{"status_id": {"2"}, "project_id": {"11"}, "tracker_id": {"1"}, "cf_3": {"Android"}, "subproject_id": {"!*"}, "limit": {"100"}, "offset": {"0"}},
+		},
+		{
+			header: "project_id=12",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		query = nil
+		req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_slug":"slug"}`))
+		req.Header.Set("REDMINE_PROJECT", "11")
+		req.Header.Set("REDMINE_FILTERS", tc.header)
+		req.Header.Set("Bitrise-Event-Type", "build/triggered")
+		rw := httptest.NewRecorder()
+		stamper.ServeHTTP(rw, req)
+		if rw.Code != tc.status {
+			t.Errorf("Wrong status code for filters %q, received: %d expected: %d", tc.header, rw.Code, tc.status)
+		}
+		if diff := cmp.Diff(query, tc.expected); diff != "" {
+			t.Errorf("Wrong Redmine query for filters %q, diff: %s", tc.header, diff)
+		}
+	}
+}
+
+func TestNewIssueQuery(t *testing.T) {
+	cases := []struct {
+		projects   string
+		expected   *IssueQuery
+		shouldFail bool
+	}{
+		{projects: "ios", expected: &IssueQuery{Project: "ios", Projects: []string{"ios"}}},
+		{projects: " ios, core ,ios,", expected: &IssueQuery{Project: "ios", Projects: []string{"ios", "core"}}},
+		{projects: " , ", shouldFail: true},
+	}
+
+	for _, tc := range cases {
+		received, err := NewIssueQuery(tc.projects, nil)
+		if (err != nil) != tc.shouldFail {
+			t.Errorf("Unexpected result for %q, error: %v", tc.projects, err)
+			continue
+		}
+		if diff := cmp.Diff(received, tc.expected); diff != "" {
+			t.Errorf("Wrong query for %q, diff: %s", tc.projects, diff)
+		}
+	}
+}
+
+func TestIssuesMergesProjects(t *testing.T) {
+	var projects []string
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		project := r.URL.Query().Get("project_id")
+		projects = append(projects, project)
+		if project == "ios" {
+			_, _ = w.Write([]byte(`{"issues":[{"id":1},{"id":2}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"issues":[{"id":2},{"id":3}]}`))
+	}))
+	defer redmine.Close()
+
+	query, _ := NewIssueQuery("ios,core", nil)
+	received, err := issues(context.Background(), http.DefaultClient, &settings.Config{Host: redmine.URL}, query)
+	if err != nil {
+		t.Fatalf("issues failed: %s", err)
+	}
+	if diff := cmp.Diff(issueIDs(received.Issues), []int{1, 2, 3}); diff != "" {
+		t.Errorf("Issues should be merged without duplicates, diff: %s", diff)
+	}
+	if diff := cmp.Diff(projects, []string{"ios", "core"}); diff != "" {
+		t.Errorf("Every project should be queried, diff: %s", diff)
+	}
+}
+
+func TestIssuesFetchesAllPages(t *testing.T) {
+	var offsets []string
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		offsets = append(offsets, r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		issues := []string{}
+		for id := offset + 1; id <= min(offset+limit, 130); id++ {
+			issues = append(issues, `{"id":`+strconv.Itoa(id)+`}`)
+		}
+		_, _ = w.Write([]byte(`{"issues":[` + strings.Join(issues, ",") + `],"total_count":130}`))
+	}))
+	defer redmine.Close()
+
+	query, _ := NewIssueQuery("ios", nil)
+	received, err := issues(context.Background(), http.DefaultClient, &settings.Config{Host: redmine.URL}, query)
+	if err != nil {
+		t.Fatalf("issues failed: %s", err)
+	}
+	if len(received.Issues) != 130 {
+		t.Errorf("Every page should be fetched, received: %d issues", len(received.Issues))
+	}
+	if diff := cmp.Diff(offsets, []string{"0", "100"}); diff != "" {
+		t.Errorf("Pages should be requested by offset, diff: %s", diff)
+	}
+}
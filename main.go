@@ -9,7 +9,6 @@ import (
 
 	"github.com/alphatroya/ci-redmine-bindings/settings"
 	"github.com/getsentry/sentry-go"
-	"github.com/go-redis/redis"
 	"github.com/rs/zerolog"
 )
 
@@ -50,7 +49,7 @@ func main() {
 	}
 	defer sentry.Flush(2 * time.Second)
 
-	stamper, err := createStamper(settings)
+	stamper, err := createStamper(context.Background(), settings)
 	if err != nil {
 		logger.Fatal().
 			Err(err).
@@ -58,6 +57,16 @@ func main() {
 	}
 	http.Handle("/bitrise", stamper)
 	http.Handle("/bitrise/v2", stamper)
+	NewHistoryHandler(stamper.history, settings, stamper.client).Register(http.DefaultServeMux)
+
+	scheduler, err := createDigestScheduler(settings, stamper)
+	if err != nil {
+		logger.Fatal().
+			Err(err).
+			Msg("wrong digest configuration")
+	}
+	go scheduler.Run(context.Background(), time.Minute)
+
 	//nolint
 	if err := http.ListenAndServe(":"+settings.Port, nil); err != nil {
 		logger.Fatal().
@@ -66,15 +75,93 @@ func main() {
 	}
 }
 
-func createStamper(settings *settings.Config) (*Stamper, error) {
-	options, err := redis.ParseURL(settings.RedisURL)
+func createStamper(ctx context.Context, settings *settings.Config) (*Stamper, error) {
+	client, err := newHTTPClient(settings.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := createStorage(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := LoadReportTemplates(settings.MailSubjectTemplate, settings.MailTextTemplate, settings.MailHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	routing := EmailRouting{OnCall: settings.EmailOnCallRecipients}
+	if settings.EmailNotifyAssignees {
+		routing.AssigneeEmail = NewUserDirectory(settings, client).Email
+	}
+	var notifiers []Notifier
+	if settings.Mailgun.Enabled {
+		mailer := NewMailgunMailer(settings.Mailgun.Domain, settings.Mailgun.APIKey, client)
+		notifiers = append(notifiers, NewEmailNotifier(mailer, settings.Mailgun.Sender, settings.Mailgun.RecipientsFor, templates, routing))
+	}
+	if settings.SMTP.Enabled {
+		mailer := NewSMTPMailer(settings.SMTP)
+		notifiers = append(notifiers, NewEmailNotifier(mailer, settings.SMTP.Sender, settings.SMTP.RecipientsFor, templates, routing))
+	}
+	if settings.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(settings.SlackWebhookURL, client))
+	}
+	if settings.TeamsWebhookURL != "" {
+		notifiers = append(notifiers, NewTeamsNotifier(settings.TeamsWebhookURL, client))
+	}
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(settings.WebhookURL, settings.WebhookHeaders, settings.WebhookSecret, client))
+	}
+	stamper := NewStamper(settings, client, storage, notifiers...)
+	filters, err := ParseIssueFilters(settings.IssueFilters)
+	if err != nil {
+		return nil, err
+	}
+	stamper.SetIssueFilters(filters)
+	if settings.VersionTemplate != "" {
+		versions, err := NewVersionManager(settings, client, settings.VersionTemplate)
+		if err != nil {
+			return nil, err
+		}
+		stamper.SetVersions(versions)
+	}
+	if settings.WikiPageTemplate != "" {
+		wiki, err := NewWikiPublisher(settings, client, settings.WikiPageTemplate, settings.WikiAppend)
+		if err != nil {
+			return nil, err
+		}
+		stamper.SetWiki(wiki)
+	}
+	if settings.LinkTemplate != "" {
+		links, err := NewBuildTemplate("build link", settings.LinkTemplate, settings.BitriseAPIToken != "")
+		if err != nil {
+			return nil, err
+		}
+		stamper.SetBuildLinks(links)
+	}
+	if settings.BuildValueTemplate != "" {
+		values, err := NewBuildTemplate("build value", settings.BuildValueTemplate, settings.BitriseAPIToken != "")
+		if err != nil {
+			return nil, err
+		}
+		stamper.SetBuildValues(values)
+	}
+	if settings.BitriseAPIToken != "" {
+		stamper.SetBitrise(NewBitriseClient(settings.BitriseAPIURL, settings.BitriseAPIToken, client))
+	}
+	outbox := NewOutbox(settings.NotifyRetries, settings.NotifyRetryBackoff)
+	go outbox.Run(ctx)
+	stamper.SetOutbox(outbox)
+	return stamper, nil
+}
+
+func createDigestScheduler(settings *settings.Config, stamper *Stamper) (*DigestScheduler, error) {
+	schedules, err := ParseDigestSchedules(settings.DigestSchedules)
 	if err != nil {
 		return nil, err
 	}
-	rdb := redis.NewClient(options)
-	_, err = rdb.Ping().Result()
+	location, err := time.LoadLocation(settings.DigestTimezone)
 	if err != nil {
 		return nil, err
 	}
-	return NewStamper(settings, rdb), nil
+	return NewDigestScheduler(stamper.history, stamper.rdb, settings.Host, schedules, location, stamper.notifiers), nil
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNothingToNotify is returned by notifiers skipping reports without processed issues
+var ErrNothingToNotify = errors.New("response object not contain neither success or failures")
+
+// ErrPartialDelivery is returned by notifiers which delivered the main report but failed
+// some secondary messages, such notifications are not retried to avoid duplicates
+var ErrPartialDelivery = errors.New("notification partially delivered")
+
+// BuildReport contains build processing results delivered by notifiers
+type BuildReport struct {
+	Response    *HookResponse
+	RedmineHost string
+	Project     string
+	BuildNumber int
+	BuildSlug   string
+	Workflow    string
+	// Issues keeps every issue known during processing, it is used to look up issue details
+	Issues  []*Issue
+	Version string
+	// SnapshotMode tells which issues changed during build were stamped, intersection is used when empty
+	SnapshotMode string
+}
+
+// Notifier delivers build report to an external service
+type Notifier interface {
+	// Name identifies notifier in logs and responses
+	Name() string
+	Notify(ctx context.Context, report *BuildReport) error
+}
+
+// ProjectName returns Redmine project name if known
+func (r *BuildReport) ProjectName() string {
+	if len(r.Issues) == 0 {
+		return ""
+	}
+	return r.Issues[0].Project.Name
+}
+
+// Subject returns short report title with Redmine project name if known
+func (r *BuildReport) Subject() string {
+	subject := "Redmine Hooks Results"
+	if name := r.ProjectName(); name != "" {
+		subject += fmt.Sprintf(" (%s)", name)
+	}
+	return subject
+}
+
+// ReportSection represents titled list of issues in report
+type ReportSection struct {
+	Title string
+	IDs   []int
+}
+
+// Sections returns non-empty issue lists of the report
+func (r *BuildReport) Sections() []ReportSection {
+	resp := r.Response
+	added, removed := "Added during build (not stamped)", "Removed during build (not stamped)"
+	switch r.SnapshotMode {
+	case SnapshotModeCached:
+		removed = "Removed during build (stamped)"
+	case SnapshotModeUnion:
+		added, removed = "Added during build (stamped)", "Removed during build (stamped)"
+	}
+	all := []ReportSection{
+		{"Success", resp.Success},
+		{"Failures", resp.Failures},
+		{added, resp.AddedDuringBuild},
+		{removed, resp.RemovedDuringBuild},
+	}
+	sections := make([]ReportSection, 0, len(all))
+	for _, section := range all {
+		if len(section.IDs) != 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// IssueURL returns link to the Redmine issue
+func (r *BuildReport) IssueURL(id int) string {
+	return fmt.Sprintf("%s/issues/%d", r.RedmineHost, id)
+}
+
+// postJSON sends encoded JSON body to notification service endpoint
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("received wrong status code %d", response.StatusCode)
+	}
+	return nil
+}
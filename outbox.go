@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog"
+)
+
+// Outbox retries failed notifications in background with exponential backoff
+type Outbox struct {
+	queue   chan *outboxItem
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+type outboxItem struct {
+	notifier Notifier
+	report   *BuildReport
+	attempt  int
+}
+
+// NewOutbox creates outbox making up to retries delivery attempts,
+// the first retry is delayed by backoff and every next one doubles the delay
+func NewOutbox(retries int, backoff time.Duration) *Outbox {
+	return &Outbox{
+		queue:   make(chan *outboxItem, 100),
+		retries: retries,
+		backoff: backoff,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Enqueue schedules retry of failed notification, returns false if outbox is full or retries are disabled
+func (o *Outbox) Enqueue(notifier Notifier, report *BuildReport) bool {
+	if o.retries <= 0 {
+		return false
+	}
+	item := &outboxItem{notifier: notifier, report: report}
+	select {
+	case o.queue <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes queued notifications until context is cancelled
+func (o *Outbox) Run(ctx context.Context) {
+	logger := zerolog.Ctx(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-o.queue:
+			delay := o.backoff << item.attempt
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+					o.deliver(ctx, item, logger)
+				}
+			}()
+		}
+	}
+}
+
+func (o *Outbox) deliver(ctx context.Context, item *outboxItem, logger *zerolog.Logger) {
+	item.attempt++
+	deliverCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	err := item.notifier.Notify(deliverCtx, item.report)
+	if errors.Is(err, ErrPartialDelivery) {
+		logger.Warn().
+			Err(err).
+			Str("notifier", item.notifier.Name()).
+			Int("attempt", item.attempt).
+			Msg("queued notification partially delivered")
+		return
+	}
+	if err == nil {
+		logger.Info().
+			Str("notifier", item.notifier.Name()).
+			Int("attempt", item.attempt).
+			Int("build number", item.report.BuildNumber).
+			Msg("queued notification delivered")
+		return
+	}
+
+	if item.attempt < o.retries {
+		logger.Warn().
+			Err(err).
+			Str("notifier", item.notifier.Name()).
+			Int("attempt", item.attempt).
+			Msg("queued notification delivery failed, retrying")
+		select {
+		case o.queue <- item:
+			return
+		default:
+		}
+	}
+	logger.Error().
+		Err(err).
+		Str("notifier", item.notifier.Name()).
+		Int("attempt", item.attempt).
+		Int("build number", item.report.BuildNumber).
+		Msg("notification dropped")
+	sentry.CaptureException(err)
+}
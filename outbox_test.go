@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockNotifier struct {
+	name     string
+	failures int
+
+	mu    sync.Mutex
+	calls int
+	done  chan struct{}
+}
+
+func (m *mockNotifier) Name() string {
+	return m.name
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.calls <= m.failures {
+		return errors.New("delivery failed")
+	}
+	if m.done != nil {
+		close(m.done)
+	}
+	return nil
+}
+
+func TestOutboxRetriesUntilDelivered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outbox := NewOutbox(3, time.Millisecond)
+	go outbox.Run(ctx)
+
+	notifier := &mockNotifier{name: "mock", failures: 2, done: make(chan struct{})}
+	if !outbox.Enqueue(notifier, &BuildReport{}) {
+		t.Fatal("Notification should be enqueued")
+	}
+
+	select {
+	case <-notifier.done:
+	case <-time.After(time.Second):
+		t.Fatal("Notification should be delivered after retries")
+	}
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.calls != 3 {
+		t.Errorf("Wrong delivery attempts count, received: %d expected: 3", notifier.calls)
+	}
+}
+
+func TestOutboxGivesUpAfterRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outbox := NewOutbox(2, time.Millisecond)
+	go outbox.Run(ctx)
+
+	notifier := &mockNotifier{name: "mock", failures: 10}
+	outbox.Enqueue(notifier, &BuildReport{})
+	time.Sleep(50 * time.Millisecond)
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.calls != 2 {
+		t.Errorf("Wrong delivery attempts count, received: %d expected: 2", notifier.calls)
+	}
+}
+
+func TestOutboxDisabled(t *testing.T) {
+	if NewOutbox(0, time.Millisecond).Enqueue(&mockNotifier{}, &BuildReport{}) {
+		t.Error("Notification should not be enqueued when retries are disabled")
+	}
+}
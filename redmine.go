@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/alphatroya/ci-redmine-bindings/settings"
 )
 
+// errRedmineNotFound is returned when requested Redmine resource doesn't exist
+var errRedmineNotFound = errors.New("redmine: resource not found")
+
 // IssuesContainer represents get issues response data
 type IssuesContainer struct {
 	Issues []*Issue `json:"issues"`
@@ -24,33 +33,275 @@ type Issue struct {
 		ID   int    `json:"id"`
 		Name string `json:"name"`
 	} `json:"project"`
+	Subject string `json:"subject"`
+	Tracker struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"tracker"`
+	AssignedTo struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"assigned_to"`
+	CustomFields []*CustomField `json:"custom_fields,omitempty"`
 }
 
-func issues(settings *settings.Config, project string) (*IssuesContainer, error) {
-	request, err := http.NewRequest("GET", settings.Host+"/issues.json?status_id="+settings.RtbStatus+"&project_id="+project, nil)
-	if err != nil {
+// CustomField represents issue custom field value
+type CustomField struct {
+	ID       int64            `json:"id"`
+	Name     string           `json:"name"`
+	Multiple bool             `json:"multiple,omitempty"`
+	Value    CustomFieldValue `json:"value"`
+}
+
+// CustomFieldValue holds single or multiple custom field values
+type CustomFieldValue []string
+
+// UnmarshalJSON decodes both string and list of strings values
+func (v *CustomFieldValue) UnmarshalJSON(data []byte) error {
+	var single *string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = nil
+		if single != nil && *single != "" {
+			*v = CustomFieldValue{*single}
+		}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("CustomFieldValue: %w", err)
+	}
+	*v = multiple
+	return nil
+}
+
+// CustomField returns issue custom field by ID or nil when the issue doesn't have it
+func (i *Issue) CustomField(id int64) *CustomField {
+	for _, field := range i.CustomFields {
+		if field.ID == id {
+			return field
+		}
+	}
+	return nil
+}
+
+// User represents single Redmine user
+type User struct {
+	ID        int    `json:"id"`
+	Login     string `json:"login"`
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	Mail      string `json:"mail"`
+}
+
+// issues fetches ready to build issues of every query project,
+// issues visible in several projects (e.g. parent and subproject) are returned once
+func issues(ctx context.Context, client *http.Client, settings *settings.Config, query *IssueQuery) (*IssuesContainer, error) {
+	projects := query.Projects
+	if len(projects) == 0 {
+		projects = []string{query.Project}
+	}
+
+	result := &IssuesContainer{Issues: []*Issue{}}
+	seen := make(map[int]bool)
+	for _, project := range projects {
+		params := make(url.Values, len(query.Filters)+2)
+		for key, value := range query.Filters {
+			params[key] = value
+		}
+		params.Set("status_id", settings.RtbStatus)
+		params.Set("project_id", project)
+		params.Set("limit", strconv.Itoa(redmineIssuesPageLimit))
+
+		// pages are requested until total count is reached, Redmine returns 25 issues by default
+		for offset := 0; ; {
+			params.Set("offset", strconv.Itoa(offset))
+			var page struct {
+				Issues     []*Issue `json:"issues"`
+				TotalCount int      `json:"total_count"`
+			}
+			if err := getRedmineJSON(ctx, client, settings, "/issues.json?"+params.Encode(), &page); err != nil {
+				return nil, fmt.Errorf("project %s: %w", project, err)
+			}
+			for _, issue := range page.Issues {
+				if !seen[issue.ID] {
+					seen[issue.ID] = true
+					result.Issues = append(result.Issues, issue)
+				}
+			}
+			offset += len(page.Issues)
+			if len(page.Issues) == 0 || offset >= page.TotalCount {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// redmineIssuesPageLimit is the maximum number of issues Redmine returns in one response
+const redmineIssuesPageLimit = 100
+
+// issuesByID fetches issues with any status by their IDs, IDs are requested in chunks
+// fitting into one Redmine response
+func issuesByID(ctx context.Context, client *http.Client, settings *settings.Config, ids []int) (*IssuesContainer, error) {
+	result := &IssuesContainer{Issues: []*Issue{}}
+	for start := 0; start < len(ids); start += redmineIssuesPageLimit {
+		chunk := ids[start:min(start+redmineIssuesPageLimit, len(ids))]
+		list := make([]string, 0, len(chunk))
+		for _, id := range chunk {
+			list = append(list, strconv.Itoa(id))
+		}
+		path := fmt.Sprintf("/issues.json?status_id=*&limit=%d&issue_id=%s", len(chunk), strings.Join(list, ","))
+		page := new(IssuesContainer)
+		if err := getRedmineJSON(ctx, client, settings, path, page); err != nil {
+			return nil, err
+		}
+		result.Issues = append(result.Issues, page.Issues...)
+	}
+	return result, nil
+}
+
+// issueByID fetches single issue with its current custom field values
+func issueByID(ctx context.Context, client *http.Client, settings *settings.Config, id int) (*Issue, error) {
+	var result struct {
+		Issue *Issue `json:"issue"`
+	}
+	if err := getRedmineJSON(ctx, client, settings, fmt.Sprintf("/issues/%d.json", id), &result); err != nil {
 		return nil, err
 	}
-	request.Header.Set("X-Redmine-API-Key", settings.AuthToken)
-	request.Header.Set("Content-Type", "application/json")
+	if result.Issue == nil {
+		return nil, fmt.Errorf("issue %d not found in response", id)
+	}
+	return result.Issue, nil
+}
 
-	response, err := http.DefaultClient.Do(request)
-	if err != nil {
+// Version represents Redmine project version
+type Version struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// versions fetches versions available to the project, including shared ones
+func versions(ctx context.Context, client *http.Client, settings *settings.Config, project string) ([]*Version, error) {
+	var result struct {
+		Versions []*Version `json:"versions"`
+	}
+	if err := getRedmineJSON(ctx, client, settings, "/projects/"+url.PathEscape(project)+"/versions.json", &result); err != nil {
+		return nil, err
+	}
+	return result.Versions, nil
+}
+
+// createVersion creates a new version in the project
+func createVersion(ctx context.Context, client *http.Client, settings *settings.Config, project, name string) (*Version, error) {
+	var result struct {
+		Version *Version `json:"version"`
+	}
+	body := map[string]interface{}{"version": map[string]string{"name": name}}
+	if err := sendRedmineJSON(ctx, client, settings, http.MethodPost, "/projects/"+url.PathEscape(project)+"/versions.json", body, &result); err != nil {
+		return nil, err
+	}
+	if result.Version == nil {
+		return nil, fmt.Errorf("version %q not found in response", name)
+	}
+	return result.Version, nil
+}
+
+// WikiPage represents Redmine project wiki page
+type WikiPage struct {
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text"`
+	Comments string `json:"comments,omitempty"`
+}
+
+func wikiPagePath(project, title string) string {
+	return "/projects/" + url.PathEscape(project) + "/wiki/" + url.PathEscape(title) + ".json"
+}
+
+// wikiPage fetches wiki page, errRedmineNotFound is returned for a missing page
+func wikiPage(ctx context.Context, client *http.Client, settings *settings.Config, project, title string) (*WikiPage, error) {
+	var result struct {
+		WikiPage *WikiPage `json:"wiki_page"`
+	}
+	if err := getRedmineJSON(ctx, client, settings, wikiPagePath(project, title), &result); err != nil {
+		return nil, err
+	}
+	if result.WikiPage == nil {
+		return nil, fmt.Errorf("wiki page %q not found in response", title)
+	}
+	return result.WikiPage, nil
+}
+
+// saveWikiPage creates or updates wiki page
+func saveWikiPage(ctx context.Context, client *http.Client, settings *settings.Config, project, title string, page *WikiPage) error {
+	body := map[string]*WikiPage{"wiki_page": page}
+	return sendRedmineJSON(ctx, client, settings, http.MethodPut, wikiPagePath(project, title), body, nil)
+}
+
+// user fetches Redmine user, email is available only for administrator API keys
+func user(ctx context.Context, client *http.Client, settings *settings.Config, id int) (*User, error) {
+	var result struct {
+		User *User `json:"user"`
+	}
+	if err := getRedmineJSON(ctx, client, settings, fmt.Sprintf("/users/%d.json", id), &result); err != nil {
 		return nil, err
 	}
+	if result.User == nil {
+		return nil, fmt.Errorf("user #%d not found in response", id)
+	}
+	return result.User, nil
+}
+
+func getRedmineJSON(ctx context.Context, client *http.Client, settings *settings.Config, path string, result interface{}) error {
+	return sendRedmineJSON(ctx, client, settings, http.MethodGet, path, nil, result)
+}
+
+// sendRedmineJSON performs Redmine API request, body and result are encoded to JSON when set
+func sendRedmineJSON(ctx context.Context, client *http.Client, settings *settings.Config, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, settings.Host+path, reader)
+	if err != nil {
+		return err
+	}
+	setRedmineHeaders(request, settings, "")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
 	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Received wrong status code %d: %w", response.StatusCode, errRedmineNotFound)
+	}
 	if response.StatusCode >= http.StatusBadRequest {
-		return nil, fmt.Errorf("Received wrong status code %d", response.StatusCode)
+		return fmt.Errorf("Received wrong status code %d", response.StatusCode)
 	}
-	data, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
+	if result == nil {
+		return nil
 	}
-	result := new(IssuesContainer)
-	err = json.Unmarshal(data, result)
+	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return json.Unmarshal(data, result)
+}
 
-	return result, nil
+// setRedmineHeaders authorizes request with the API key and optional Basic credentials,
+// switchUser makes Redmine perform the request on behalf of the user
+func setRedmineHeaders(request *http.Request, settings *settings.Config, switchUser string) {
+	request.Header.Set("X-Redmine-API-Key", settings.AuthToken)
+	request.Header.Set("Content-Type", "application/json")
+	if settings.BasicUser != "" {
+		request.SetBasicAuth(settings.BasicUser, settings.BasicPassword)
+	}
+	if switchUser != "" {
+		request.Header.Set("X-Redmine-Switch-User", switchUser)
+	}
 }
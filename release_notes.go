@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"sort"
+	texttemplate "text/template"
+)
+
+var (
+	releaseNotesMarkdown = texttemplate.Must(texttemplate.ParseFS(defaultTemplates, "templates/release_notes.md.tmpl"))
+	releaseNotesHTML     = htmltemplate.Must(htmltemplate.ParseFS(defaultTemplates, "templates/release_notes.html.tmpl"))
+)
+
+// releaseNotesOtherTracker groups issues without tracker information
+const releaseNotesOtherTracker = "Other"
+
+// ReleaseNotes lists stamped issues of the build grouped by tracker
+type ReleaseNotes struct {
+	Project     string
+	BuildNumber int
+	RedmineHost string
+	Sections    []*ReleaseNotesSection
+}
+
+// ReleaseNotesSection contains build issues of a single tracker
+type ReleaseNotesSection struct {
+	Tracker string
+	Issues  []*Issue
+}
+
+// NewReleaseNotes groups stamped issues by tracker, sections are ordered by tracker ID
+// so Redmine defaults (Bug, Feature, Support) keep their order.
+// Stamped issues missing from the issues list are grouped into "Other" section
+func NewReleaseNotes(project string, buildNumber int, redmineHost string, issues []*Issue, stamped []int) *ReleaseNotes {
+	known := make(map[int]*Issue, len(issues))
+	for _, issue := range issues {
+		known[issue.ID] = issue
+	}
+
+	sections := make(map[int]*ReleaseNotesSection)
+	var order []int
+	for _, id := range stamped {
+		issue, ok := known[id]
+		if !ok {
+			issue = &Issue{ID: id}
+		}
+		trackerID := issue.Tracker.ID
+		if issue.Tracker.Name == "" {
+			trackerID = 0
+		}
+		section, ok := sections[trackerID]
+		if !ok {
+			section = &ReleaseNotesSection{Tracker: issue.Tracker.Name}
+			if trackerID == 0 {
+				section.Tracker = releaseNotesOtherTracker
+			}
+			sections[trackerID] = section
+			order = append(order, trackerID)
+		}
+		section.Issues = append(section.Issues, issue)
+	}
+
+	// "Other" section goes last
+	rank := func(trackerID int) int {
+		if trackerID == 0 {
+			return math.MaxInt
+		}
+		return trackerID
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return rank(order[i]) < rank(order[j])
+	})
+	notes := &ReleaseNotes{Project: project, BuildNumber: buildNumber, RedmineHost: redmineHost}
+	for _, trackerID := range order {
+		section := sections[trackerID]
+		sort.Slice(section.Issues, func(i, j int) bool {
+			return section.Issues[i].ID < section.Issues[j].ID
+		})
+		notes.Sections = append(notes.Sections, section)
+	}
+	return notes
+}
+
+// IssueURL returns Redmine issue page address
+func (n *ReleaseNotes) IssueURL(id int) string {
+	return fmt.Sprintf("%s/issues/%d", n.RedmineHost, id)
+}
+
+// Markdown renders release notes suitable for TestFlight and store descriptions
+func (n *ReleaseNotes) Markdown() (string, error) {
+	var buffer bytes.Buffer
+	if err := releaseNotesMarkdown.Execute(&buffer, n); err != nil {
+		return "", fmt.Errorf("ReleaseNotes: markdown: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+// HTML renders release notes page with links to Redmine issues
+func (n *ReleaseNotes) HTML() (string, error) {
+	var buffer bytes.Buffer
+	if err := releaseNotesHTML.Execute(&buffer, n); err != nil {
+		return "", fmt.Errorf("ReleaseNotes: html: %w", err)
+	}
+	return buffer.String(), nil
+}
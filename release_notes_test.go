@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReleaseNotesGroupsIssuesByTracker(t *testing.T) {
+	issues := []*Issue{
+		{ID: 5, Subject: "Write docs"},
+		{ID: 4, Subject: "Dark mode"},
+		{ID: 3, Subject: "Crash on start"},
+		{ID: 2, Subject: "Wrong title"},
+		{ID: 1, Subject: "Not stamped"},
+	}
+	issues[0].Tracker.ID, issues[0].Tracker.Name = 4, "Task"
+	issues[1].Tracker.ID, issues[1].Tracker.Name = 2, "Feature"
+	issues[2].Tracker.ID, issues[2].Tracker.Name = 1, "Bug"
+	issues[3].Tracker.ID, issues[3].Tracker.Name = 1, "Bug"
+
+	notes := NewReleaseNotes("ios", 12, "https://redmine.org", issues, []int{5, 3, 9, 4, 2})
+	received, err := notes.Markdown()
+	if err != nil {
+		t.Fatalf("Can't render release notes: %s", err)
+	}
+	expected := strings.Join([]string{
+		"# ios build 12",
+		"",
+		"## Bug",
+		"",
+		"- Wrong title (#2)",
+		"- Crash on start (#3)",
+		"",
+		"## Feature",
+		"",
+		"- Dark mode (#4)",
+		"",
+		"## Task",
+		"",
+		"- Write docs (#5)",
+		"",
+		"## Other",
+		"",
+		"- (#9)",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong markdown release notes, diff: %s", diff)
+	}
+
+	html, err := notes.HTML()
+	if err != nil {
+		t.Fatalf("Can't render release notes: %s", err)
+	}
+	if !strings.Contains(html, `<li>Crash on start <a href="https://redmine.org/issues/3">#3</a></li>`) {
+		t.Errorf("HTML release notes should link issues, received: %s", html)
+	}
+}
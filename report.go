@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// ReportTemplates renders build report emails
+type ReportTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+
+	personalSubject *texttemplate.Template
+	personalText    *texttemplate.Template
+	personalHTML    *htmltemplate.Template
+
+	digestSubject *texttemplate.Template
+	digestText    *texttemplate.Template
+	digestHTML    *htmltemplate.Template
+}
+
+// RenderedReport represents build report ready to be sent
+type RenderedReport struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// LoadReportTemplates parses report templates, embedded default is used for every empty path
+func LoadReportTemplates(subjectPath, textPath, htmlPath string) (*ReportTemplates, error) {
+	subjectSource, err := readTemplate(subjectPath, "templates/report_subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	textSource, err := readTemplate(textPath, "templates/report.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	htmlSource, err := readTemplate(htmlPath, "templates/report.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := new(ReportTemplates)
+	if templates.subject, err = texttemplate.New("subject").Parse(subjectSource); err != nil {
+		return nil, fmt.Errorf("LoadReportTemplates: wrong subject template: %w", err)
+	}
+	if templates.text, err = texttemplate.New("text").Parse(textSource); err != nil {
+		return nil, fmt.Errorf("LoadReportTemplates: wrong text template: %w", err)
+	}
+	if templates.html, err = htmltemplate.New("html").Parse(htmlSource); err != nil {
+		return nil, fmt.Errorf("LoadReportTemplates: wrong html template: %w", err)
+	}
+
+	templates.personalSubject = texttemplate.Must(texttemplate.ParseFS(defaultTemplates, "templates/personal_subject.tmpl"))
+	templates.personalText = texttemplate.Must(texttemplate.ParseFS(defaultTemplates, "templates/personal.txt.tmpl"))
+	templates.personalHTML = htmltemplate.Must(htmltemplate.ParseFS(defaultTemplates, "templates/personal.html.tmpl"))
+	templates.digestSubject = texttemplate.Must(texttemplate.ParseFS(defaultTemplates, "templates/digest_subject.tmpl"))
+	templates.digestText = texttemplate.Must(texttemplate.ParseFS(defaultTemplates, "templates/digest.txt.tmpl"))
+	templates.digestHTML = htmltemplate.Must(htmltemplate.ParseFS(defaultTemplates, "templates/digest.html.tmpl"))
+	return templates, nil
+}
+
+func readTemplate(path, fallback string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "" {
+		data, err = defaultTemplates.ReadFile(fallback)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("readTemplate: can't read template: %w", err)
+	}
+	return string(data), nil
+}
+
+// reportView is a data passed to report templates
+type reportView struct {
+	*BuildReport
+	Subject  string
+	Sections []reportViewSection
+}
+
+type reportViewSection struct {
+	Title  string
+	Issues []reportViewIssue
+}
+
+type reportViewIssue struct {
+	ID       int
+	URL      string
+	Subject  string
+	Tracker  string
+	Assignee string
+}
+
+func newReportView(report *BuildReport) *reportView {
+	known := make(map[int]*Issue, len(report.Issues))
+	for _, issue := range report.Issues {
+		known[issue.ID] = issue
+	}
+
+	view := &reportView{BuildReport: report}
+	for _, section := range report.Sections() {
+		viewSection := reportViewSection{Title: section.Title}
+		for _, id := range section.IDs {
+			item := reportViewIssue{ID: id, URL: report.IssueURL(id)}
+			if issue, ok := known[id]; ok {
+				item.Subject = issue.Subject
+				item.Tracker = issue.Tracker.Name
+				item.Assignee = issue.AssignedTo.Name
+			}
+			viewSection.Issues = append(viewSection.Issues, item)
+		}
+		view.Sections = append(view.Sections, viewSection)
+	}
+	return view
+}
+
+// personalView is a data passed to personal assignee email templates
+type personalView struct {
+	*BuildReport
+	Assignee string
+	Issues   []reportViewIssue
+}
+
+// Render executes all templates for the report
+func (t *ReportTemplates) Render(report *BuildReport) (*RenderedReport, error) {
+	view := newReportView(report)
+	return renderEmail(t.subject, t.text, t.html, view, func(subject string) {
+		view.Subject = subject
+	})
+}
+
+// RenderPersonal renders email for assignee whose issues were stamped in the build
+func (t *ReportTemplates) RenderPersonal(report *BuildReport, assignee string, issues []*Issue) (*RenderedReport, error) {
+	view := &personalView{BuildReport: report, Assignee: assignee}
+	for _, issue := range issues {
+		view.Issues = append(view.Issues, reportViewIssue{
+			ID:       issue.ID,
+			URL:      report.IssueURL(issue.ID),
+			Subject:  issue.Subject,
+			Tracker:  issue.Tracker.Name,
+			Assignee: issue.AssignedTo.Name,
+		})
+	}
+	return renderEmail(t.personalSubject, t.personalText, t.personalHTML, view, nil)
+}
+
+// digestView is a data passed to digest templates
+type digestView struct {
+	*Digest
+	Subject string
+}
+
+// RenderDigest renders periodic project digest email
+func (t *ReportTemplates) RenderDigest(digest *Digest) (*RenderedReport, error) {
+	view := &digestView{Digest: digest}
+	return renderEmail(t.digestSubject, t.digestText, t.digestHTML, view, func(subject string) {
+		view.Subject = subject
+	})
+}
+
+// renderEmail executes email templates, subject is passed to setSubject before rendering the body
+func renderEmail(subject, text *texttemplate.Template, html *htmltemplate.Template, view interface{}, setSubject func(string)) (*RenderedReport, error) {
+	var buffer bytes.Buffer
+	if err := subject.Execute(&buffer, view); err != nil {
+		return nil, fmt.Errorf("Render: subject: %w", err)
+	}
+	rendered := &RenderedReport{Subject: strings.TrimSpace(buffer.String())}
+	if setSubject != nil {
+		setSubject(rendered.Subject)
+	}
+
+	buffer.Reset()
+	if err := text.Execute(&buffer, view); err != nil {
+		return nil, fmt.Errorf("Render: text: %w", err)
+	}
+	rendered.Text = buffer.String()
+
+	buffer.Reset()
+	if err := html.Execute(&buffer, view); err != nil {
+		return nil, fmt.Errorf("Render: html: %w", err)
+	}
+	rendered.HTML = buffer.String()
+	return rendered, nil
+}
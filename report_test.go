@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func newTestReport() *BuildReport {
+	issues := []*Issue{{ID: 1, Subject: "Crash on <launch>"}, {ID: 2, Subject: "Dark mode"}, {ID: 3}}
+	for _, issue := range issues {
+		issue.Project.Name = "App"
+	}
+	issues[0].Tracker.Name = "Bug"
+	issues[0].AssignedTo.Name = "John Doe"
+	issues[1].Tracker.Name = "Feature"
+
+	return &BuildReport{
+		Response: &HookResponse{
+			Success:          []int{1, 2},
+			Failures:         []int{3},
+			AddedDuringBuild: []int{4},
+		},
+		RedmineHost: "https://redmine.org",
+		Project:     "app",
+		BuildNumber: 512,
+		BuildSlug:   "a1b2c3",
+		Workflow:    "internal",
+		Issues:      issues,
+		Version:     "v2 cached",
+	}
+}
+
+func assertGolden(t *testing.T, name, received string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(received), 0o600); err != nil {
+			t.Fatalf("Can't update golden file: %s", err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Can't read golden file: %s", err)
+	}
+	if diff := cmp.Diff(received, string(expected)); diff != "" {
+		t.Errorf("Rendered %s differs from golden file, diff: %s", name, diff)
+	}
+}
+
+func TestReportTemplatesDefaultRendering(t *testing.T) {
+	templates, err := LoadReportTemplates("", "", "")
+	if err != nil {
+		t.Fatalf("Can't load default templates: %s", err)
+	}
+	rendered, err := templates.Render(newTestReport())
+	if err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+
+	if expected := "Redmine Hooks Results (App): build 512"; rendered.Subject != expected {
+		t.Errorf("Wrong subject\nreceived: %q\nexpected: %q", rendered.Subject, expected)
+	}
+	assertGolden(t, "report.txt.golden", rendered.Text)
+	assertGolden(t, "report.html.golden", rendered.HTML)
+}
+
+func TestReportTemplatesOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subject.tmpl")
+	_ = os.WriteFile(path, []byte("Build {{.BuildNumber}} of {{.Project}}"), 0o600)
+
+	templates, err := LoadReportTemplates(path, "", "")
+	if err != nil {
+		t.Fatalf("Can't load templates: %s", err)
+	}
+	rendered, err := templates.Render(newTestReport())
+	if err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+	if expected := "Build 512 of app"; rendered.Subject != expected {
+		t.Errorf("Wrong subject\nreceived: %q\nexpected: %q", rendered.Subject, expected)
+	}
+}
+
+func TestReportTemplatesLoadFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.tmpl")
+	_ = os.WriteFile(path, []byte("{{.BuildNumber"), 0o600)
+
+	cases := [][3]string{
+		{path, "", ""},
+		{"", path, ""},
+		{"", "", path},
+		{"", "", "missing.tmpl"},
+	}
+	for i, tc := range cases {
+		if _, err := LoadReportTemplates(tc[0], tc[1], tc[2]); err == nil {
+			t.Errorf("Test case #%d should fail", i)
+		}
+	}
+}
+
+func TestReportSectionsFollowSnapshotMode(t *testing.T) {
+	cases := map[string][]string{
+		"":                       {"Success", "Failures", "Added during build (not stamped)", "Removed during build (not stamped)"},
+		SnapshotModeIntersection: {"Success", "Failures", "Added during build (not stamped)", "Removed during build (not stamped)"},
+		SnapshotModeCached:       {"Success", "Failures", "Added during build (not stamped)", "Removed during build (stamped)"},
+		SnapshotModeUnion:        {"Success", "Failures", "Added during build (stamped)", "Removed during build (stamped)"},
+	}
+
+	for mode, expected := range cases {
+		report := newTestReport()
+		report.Response.RemovedDuringBuild = []int{5}
+		report.SnapshotMode = mode
+		var received []string
+		for _, section := range report.Sections() {
+			received = append(received, section.Title)
+		}
+		if diff := cmp.Diff(received, expected); diff != "" {
+			t.Errorf("Wrong section titles for mode %q, diff: %s", mode, diff)
+		}
+	}
+}
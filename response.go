@@ -2,12 +2,48 @@ package main
 
 // HookResponse represents success message response
 type HookResponse struct {
-	Message  string `json:"message"`
-	Success  []int  `json:"success"`
-	Failures []int  `json:"failures"`
+	Message            string     `json:"message"`
+	Success            []int      `json:"success"`
+	Failures           []int      `json:"failures"`
+	Cache              *CacheInfo `json:"cache,omitempty"`
+	AddedDuringBuild   []int      `json:"added_during_build,omitempty"`
+	RemovedDuringBuild []int      `json:"removed_during_build,omitempty"`
+	ReleaseNotes       string     `json:"release_notes,omitempty"`
+	FixedVersion       *Version   `json:"fixed_version,omitempty"`
+	WikiPage           string     `json:"wiki_page,omitempty"`
+
+	Notifications []*NotificationResult `json:"notifications,omitempty"`
+}
+
+// Notification delivery statuses
+const (
+	NotificationDelivered = "delivered"
+	NotificationSkipped   = "skipped"
+	NotificationPartial   = "partial"
+	NotificationQueued    = "queued"
+	NotificationFailed    = "failed"
+)
+
+// NotificationResult describes delivery of build report by a notifier
+type NotificationResult struct {
+	Notifier string `json:"notifier"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Issues snapshot cache statuses
+const (
+	CacheStatusHit      = "hit"
+	CacheStatusFallback = "fallback"
+)
+
+// CacheInfo describes where the processed issues list came from
+type CacheInfo struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // NewResponse create empty response with message
 func NewResponse(message string) *HookResponse {
-	return &HookResponse{message, []int{}, []int{}}
+	return &HookResponse{Message: message, Success: []int{}, Failures: []int{}}
 }
@@ -1,17 +1,230 @@
 package settings
 
-import "github.com/ilyakaznacheev/cleanenv"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
 
 // Config struct combine app settings
 type Config struct {
-	RedisURL     string `env:"REDIS_URL"                   env-required:"true"`
-	Host         string `env:"REDMINE_HOST"                env-required:"true"`
-	AuthToken    string `env:"REDMINE_API_KEY"             env-required:"true"`
-	RtbStatus    string `env:"STAMP_READY_TO_BUILD_STATUS" env-required:"true"`
-	BuildFieldID int64  `env:"STAMP_BUILD_CUSTOM_FIELD"    env-required:"true"`
-	DoneStatus   string `env:"STAMP_DONE_STATUS"           env-required:"true"`
-	Port         string `env:"PORT"                                            env-default:"8080"`
-	SentryDSN    string `env:"SENTRY_DSN"                  env-required:"true"`
+	StorageBackend string        `env:"STORAGE_BACKEND"                                 env-default:"redis"`
+	RedisURL       string        `env:"REDIS_URL"`
+	RedisMode      string        `env:"REDIS_MODE"                                      env-default:"standalone"`
+	RedisTimeout   time.Duration `env:"REDIS_TIMEOUT"                                   env-default:"3s"`
+	BoltPath       string        `env:"BOLT_PATH"                                       env-default:"hook.db"`
+	Host           string        `env:"REDMINE_HOST"                env-required:"true"`
+	AuthToken      string        `env:"REDMINE_API_KEY"             env-required:"true"`
+	RtbStatus      string        `env:"STAMP_READY_TO_BUILD_STATUS" env-required:"true"`
+	BuildFieldID   int64         `env:"STAMP_BUILD_CUSTOM_FIELD"    env-required:"true"`
+	DoneStatus     string        `env:"STAMP_DONE_STATUS"           env-required:"true"`
+	Port           string        `env:"PORT"                                            env-default:"8080"`
+	SentryDSN      string        `env:"SENTRY_DSN"                  env-required:"true"`
+
+	BasicUser     string `env:"REDMINE_BASIC_USER"`
+	BasicPassword string `env:"REDMINE_BASIC_PASSWORD"`
+	SwitchUser    string `env:"REDMINE_SWITCH_USER"`
+	// SwitchUsers maps Bitrise user who triggered the build to Redmine login
+	SwitchUsers map[string]string `env:"REDMINE_SWITCH_USERS"`
+
+	IssueFilters       string `env:"STAMP_ISSUE_FILTERS"`
+	BuildValueTemplate string `env:"STAMP_BUILD_VALUE_TEMPLATE"`
+	VersionTemplate    string `env:"STAMP_VERSION_TEMPLATE"`
+	WikiPageTemplate   string `env:"STAMP_WIKI_PAGE_TEMPLATE"`
+	WikiAppend         bool   `env:"STAMP_WIKI_APPEND"`
+	WikiTextTemplate   string `env:"STAMP_WIKI_TEXT_TEMPLATE"`
+	LinkTemplate       string `env:"STAMP_BUILD_LINK_TEMPLATE"`
+	LinkFieldID        int64  `env:"STAMP_LINK_CUSTOM_FIELD"`
+
+	BitriseAPIURL   string `env:"BITRISE_API_URL"   env-default:"https://api.bitrise.io/v0.1"`
+	BitriseAPIToken string `env:"BITRISE_API_TOKEN"`
+
+	CacheTTL         time.Duration            `env:"CACHE_TTL"          env-default:"4h"`
+	ProjectCacheTTLs map[string]time.Duration `env:"CACHE_TTL_PROJECTS"`
+	SnapshotMode     string                   `env:"STAMP_SNAPSHOT_MODE" env-default:"intersection"`
+
+	BuildFieldMode         string            `env:"STAMP_BUILD_FIELD_MODE"          env-default:"replace"`
+	ProjectBuildFieldModes map[string]string `env:"STAMP_BUILD_FIELD_MODE_PROJECTS"`
+
+	SlackWebhookURL string            `env:"SLACK_WEBHOOK_URL"`
+	TeamsWebhookURL string            `env:"TEAMS_WEBHOOK_URL"`
+	WebhookURL      string            `env:"WEBHOOK_URL"`
+	WebhookHeaders  map[string]string `env:"WEBHOOK_HEADERS"`
+	WebhookSecret   string            `env:"WEBHOOK_SECRET"`
+
+	NotifyRetries      int           `env:"NOTIFY_RETRIES"       env-default:"3"`
+	NotifyRetryBackoff time.Duration `env:"NOTIFY_RETRY_BACKOFF" env-default:"30s"`
+
+	MailSubjectTemplate string `env:"MAIL_SUBJECT_TEMPLATE"`
+	MailTextTemplate    string `env:"MAIL_TEXT_TEMPLATE"`
+	MailHTMLTemplate    string `env:"MAIL_HTML_TEMPLATE"`
+
+	EmailNotifyAssignees  bool     `env:"EMAIL_NOTIFY_ASSIGNEES"`
+	EmailOnCallRecipients []string `env:"EMAIL_ONCALL_RECIPIENTS"`
+
+	DigestSchedules map[string]string `env:"DIGEST_SCHEDULES"`
+	DigestTimezone  string            `env:"DIGEST_TIMEZONE"  env-default:"UTC"`
+
+	Mailgun Mailgun
+	SMTP    SMTP
+	HTTP    HTTP
+}
+
+// CacheTTLFor returns issues snapshot lifetime for the Redmine project
+func (c *Config) CacheTTLFor(project string) time.Duration {
+	if ttl, ok := c.ProjectCacheTTLs[project]; ok {
+		return ttl
+	}
+	return c.CacheTTL
+}
+
+// SwitchUserFor returns Redmine login issues are stamped on behalf of for the Bitrise user
+func (c *Config) SwitchUserFor(triggeredBy string) string {
+	if login, ok := c.SwitchUsers[triggeredBy]; ok && triggeredBy != "" {
+		return login
+	}
+	return c.SwitchUser
+}
+
+// BuildFieldModeFor returns how build number is written into the build custom field of the Redmine project
+func (c *Config) BuildFieldModeFor(project string) string {
+	if mode, ok := c.ProjectBuildFieldModes[project]; ok {
+		return mode
+	}
+	return c.BuildFieldMode
+}
+
+// Mailgun struct combine Mailgun notifier settings
+type Mailgun struct {
+	Enabled    bool     `env:"MAILGUN_ENABLED"`
+	APIKey     string   `env:"MAILGUN_API"`
+	Domain     string   `env:"MAILGUN_DOMAIN"`
+	Sender     string   `env:"MAILGUN_SENDER"`
+	Recipients []string `env:"MAILGUN_RECIPIENT"`
+	// ProjectRecipients maps Redmine project to semicolon separated recipients list
+	ProjectRecipients map[string]string `env:"MAILGUN_PROJECT_RECIPIENTS"`
+}
+
+// RecipientsFor returns email recipients for the Redmine project,
+// project specific list replaces the default one
+func (m *Mailgun) RecipientsFor(project string) []string {
+	return recipientsFor(m.Recipients, m.ProjectRecipients, project)
+}
+
+func (m *Mailgun) validate() error {
+	if !m.Enabled {
+		if m.APIKey != "" || m.Domain != "" {
+			return errors.New("MAILGUN_API or MAILGUN_DOMAIN is set but MAILGUN_ENABLED is not true, remove credentials to disable emails")
+		}
+		return nil
+	}
+	if m.APIKey == "" || m.Domain == "" || m.Sender == "" {
+		return errors.New("MAILGUN_API, MAILGUN_DOMAIN and MAILGUN_SENDER are required when MAILGUN_ENABLED is set")
+	}
+	if len(m.Recipients) == 0 && len(m.ProjectRecipients) == 0 {
+		return errors.New("MAILGUN_RECIPIENT or MAILGUN_PROJECT_RECIPIENTS is required when MAILGUN_ENABLED is set")
+	}
+	return nil
+}
+
+// SMTP struct combine SMTP relay notifier settings
+type SMTP struct {
+	Enabled    bool     `env:"SMTP_ENABLED"`
+	Host       string   `env:"SMTP_HOST"`
+	Port       int      `env:"SMTP_PORT"     env-default:"587"`
+	Username   string   `env:"SMTP_USERNAME"`
+	Password   string   `env:"SMTP_PASSWORD"`
+	StartTLS   bool     `env:"SMTP_STARTTLS" env-default:"true"`
+	Sender     string   `env:"SMTP_SENDER"`
+	Recipients []string `env:"SMTP_RECIPIENT"`
+	// ProjectRecipients maps Redmine project to semicolon separated recipients list
+	ProjectRecipients map[string]string `env:"SMTP_PROJECT_RECIPIENTS"`
+}
+
+// RecipientsFor returns email recipients for the Redmine project,
+// project specific list replaces the default one
+func (s *SMTP) RecipientsFor(project string) []string {
+	return recipientsFor(s.Recipients, s.ProjectRecipients, project)
+}
+
+func (s *SMTP) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.Host == "" || s.Sender == "" {
+		return errors.New("SMTP_HOST and SMTP_SENDER are required when SMTP_ENABLED is set")
+	}
+	if len(s.Recipients) == 0 && len(s.ProjectRecipients) == 0 {
+		return errors.New("SMTP_RECIPIENT or SMTP_PROJECT_RECIPIENTS is required when SMTP_ENABLED is set")
+	}
+	return nil
+}
+
+// HTTP struct combine outbound HTTP clients TLS and proxy settings
+type HTTP struct {
+	CABundle           string `env:"HTTP_CA_BUNDLE"`
+	ClientCert         string `env:"HTTP_CLIENT_CERT"`
+	ClientKey          string `env:"HTTP_CLIENT_KEY"`
+	InsecureSkipVerify bool   `env:"HTTP_INSECURE_SKIP_VERIFY"`
+	ProxyURL           string `env:"HTTP_PROXY_URL"`
+}
+
+func (h *HTTP) validate() error {
+	if (h.ClientCert == "") != (h.ClientKey == "") {
+		return errors.New("HTTP_CLIENT_CERT and HTTP_CLIENT_KEY should be set together")
+	}
+	return nil
+}
+
+func recipientsFor(defaults []string, projects map[string]string, project string) []string {
+	list, ok := projects[project]
+	if !ok {
+		return defaults
+	}
+	var recipients []string
+	for _, recipient := range strings.Split(list, ";") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients
+}
+
+func (c *Config) validate() error {
+	if err := c.Mailgun.validate(); err != nil {
+		return err
+	}
+	if err := c.SMTP.validate(); err != nil {
+		return err
+	}
+	if err := c.HTTP.validate(); err != nil {
+		return err
+	}
+	modes := []string{c.BuildFieldMode}
+	for _, mode := range c.ProjectBuildFieldModes {
+		modes = append(modes, mode)
+	}
+	for _, mode := range modes {
+		if mode != "replace" && mode != "append" && mode != "list" {
+			return fmt.Errorf("wrong build field mode %q, should be one of replace, append or list", mode)
+		}
+	}
+	if c.SnapshotMode != "intersection" && c.SnapshotMode != "cached" && c.SnapshotMode != "union" {
+		return fmt.Errorf("wrong snapshot mode %q, should be one of intersection, cached or union", c.SnapshotMode)
+	}
+	if (c.BasicUser == "") != (c.BasicPassword == "") {
+		return errors.New("REDMINE_BASIC_USER and REDMINE_BASIC_PASSWORD should be set together")
+	}
+	if c.LinkFieldID != 0 && c.LinkTemplate == "" {
+		return errors.New("STAMP_LINK_CUSTOM_FIELD requires STAMP_BUILD_LINK_TEMPLATE to be set")
+	}
+	if c.Mailgun.Enabled && c.SMTP.Enabled {
+		return errors.New("only one of MAILGUN_ENABLED and SMTP_ENABLED email backends can be set")
+	}
+	return nil
 }
 
 func Current() (*Config, error) {
@@ -20,6 +233,9 @@ func Current() (*Config, error) {
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
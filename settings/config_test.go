@@ -3,6 +3,7 @@ package settings
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -99,14 +100,26 @@ func Test_Current(t *testing.T) {
 				"SENTRY_DSN":                  "sentry",
 			},
 			expected: &Config{
-				RedisURL:     "redis",
-				Host:         "https://google.com",
-				AuthToken:    "11881",
-				RtbStatus:    "1",
-				BuildFieldID: 1,
-				DoneStatus:   "1222",
-				Port:         "8080",
-				SentryDSN:    "sentry",
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
 			},
 		},
 		{
@@ -122,16 +135,390 @@ func Test_Current(t *testing.T) {
 				"SENTRY_DSN":                  "sentry",
 			},
 			expected: &Config{
-				RedisURL:     "redis",
-				Host:         "https://google.com",
-				AuthToken:    "11881",
-				RtbStatus:    "1",
-				BuildFieldID: 1,
-				DoneStatus:   "1222",
-				Port:         "8084",
-				SentryDSN:    "sentry",
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8084",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
 			},
 		},
+		{
+			name: "bolt storage without redis url",
+			envs: map[string]string{
+				"STORAGE_BACKEND":             "bolt",
+				"BOLT_PATH":                   "/data/hook.db",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+			},
+			expected: &Config{
+				StorageBackend:     "bolt",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "/data/hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
+			},
+		},
+		{
+			name: "cache ttl per project",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"CACHE_TTL":                   "6h",
+				"CACHE_TTL_PROJECTS":          "ios:12h,android:30m",
+			},
+			expected: &Config{
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           6 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
+				ProjectCacheTTLs: map[string]time.Duration{
+					"ios":     12 * time.Hour,
+					"android": 30 * time.Minute,
+				},
+			},
+		},
+		{
+			name: "mailgun enabled",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"MAILGUN_ENABLED":             "true",
+				"MAILGUN_API":                 "key",
+				"MAILGUN_DOMAIN":              "mg.google.com",
+				"MAILGUN_SENDER":              "bot@google.com",
+				"MAILGUN_RECIPIENT":           "qa@google.com,pm@google.com",
+				"MAILGUN_PROJECT_RECIPIENTS":  "ios:ios@google.com;lead@google.com",
+			},
+			expected: &Config{
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
+				Mailgun: Mailgun{
+					Enabled:           true,
+					APIKey:            "key",
+					Domain:            "mg.google.com",
+					Sender:            "bot@google.com",
+					Recipients:        []string{"qa@google.com", "pm@google.com"},
+					ProjectRecipients: map[string]string{"ios": "ios@google.com;lead@google.com"},
+				},
+			},
+		},
+		{
+			name: "digest schedules",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"DIGEST_SCHEDULES":            "ios:daily 09:00,android:weekly mon 10:30",
+				"DIGEST_TIMEZONE":             "Europe/Moscow",
+			},
+			expected: &Config{
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestSchedules:    map[string]string{"ios": "daily 09:00", "android": "weekly mon 10:30"},
+				DigestTimezone:     "Europe/Moscow",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP:               SMTP{Port: 587, StartTLS: true},
+			},
+		},
+		{
+			name: "wrong project build field mode",
+			envs: map[string]string{
+				"REDIS_URL":                       "redis",
+				"REDMINE_HOST":                    "https://google.com",
+				"REDMINE_API_KEY":                 "11881",
+				"STAMP_READY_TO_BUILD_STATUS":     "1",
+				"STAMP_BUILD_CUSTOM_FIELD":        "1",
+				"STAMP_DONE_STATUS":               "1222",
+				"SENTRY_DSN":                      "sentry",
+				"STAMP_BUILD_FIELD_MODE_PROJECTS": "ios:append,android:merge",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "wrong snapshot mode",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"STAMP_SNAPSHOT_MODE":         "latest",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "basic auth without password",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"REDMINE_BASIC_USER":          "proxy",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "client certificate without key",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"HTTP_CLIENT_CERT":            "client.crt",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "link custom field without template",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"STAMP_LINK_CUSTOM_FIELD":     "2",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "mailgun enabled without sender",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"MAILGUN_ENABLED":             "true",
+				"MAILGUN_API":                 "key",
+				"MAILGUN_DOMAIN":              "mg.google.com",
+				"MAILGUN_RECIPIENT":           "qa@google.com",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "mailgun enabled without recipients",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"MAILGUN_ENABLED":             "true",
+				"MAILGUN_API":                 "key",
+				"MAILGUN_DOMAIN":              "mg.google.com",
+				"MAILGUN_SENDER":              "bot@google.com",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "smtp enabled",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"SMTP_ENABLED":                "true",
+				"SMTP_HOST":                   "smtp.google.com",
+				"SMTP_PORT":                   "25",
+				"SMTP_STARTTLS":               "false",
+				"SMTP_SENDER":                 "bot@google.com",
+				"SMTP_RECIPIENT":              "qa@google.com",
+			},
+			expected: &Config{
+				StorageBackend:     "redis",
+				RedisURL:           "redis",
+				RedisMode:          "standalone",
+				RedisTimeout:       3 * time.Second,
+				BoltPath:           "hook.db",
+				Host:               "https://google.com",
+				AuthToken:          "11881",
+				RtbStatus:          "1",
+				BuildFieldID:       1,
+				DoneStatus:         "1222",
+				Port:               "8080",
+				SentryDSN:          "sentry",
+				CacheTTL:           4 * time.Hour,
+				SnapshotMode:       "intersection",
+				BuildFieldMode:     "replace",
+				NotifyRetries:      3,
+				NotifyRetryBackoff: 30 * time.Second,
+				DigestTimezone:     "UTC",
+				BitriseAPIURL:      "https://api.bitrise.io/v0.1",
+				SMTP: SMTP{
+					Enabled:    true,
+					Host:       "smtp.google.com",
+					Port:       25,
+					Sender:     "bot@google.com",
+					Recipients: []string{"qa@google.com"},
+				},
+			},
+		},
+		{
+			name: "smtp enabled without host",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"SMTP_ENABLED":                "true",
+				"SMTP_SENDER":                 "bot@google.com",
+				"SMTP_RECIPIENT":              "qa@google.com",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "both email backends enabled",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"MAILGUN_ENABLED":             "true",
+				"MAILGUN_API":                 "key",
+				"MAILGUN_DOMAIN":              "mg.google.com",
+				"MAILGUN_SENDER":              "bot@google.com",
+				"MAILGUN_RECIPIENT":           "qa@google.com",
+				"SMTP_ENABLED":                "true",
+				"SMTP_HOST":                   "smtp.google.com",
+				"SMTP_SENDER":                 "bot@google.com",
+				"SMTP_RECIPIENT":              "qa@google.com",
+			},
+			shouldFail: true,
+		},
+		{
+			name: "mailgun credentials without enabling",
+			envs: map[string]string{
+				"REDIS_URL":                   "redis",
+				"REDMINE_HOST":                "https://google.com",
+				"REDMINE_API_KEY":             "11881",
+				"STAMP_READY_TO_BUILD_STATUS": "1",
+				"STAMP_BUILD_CUSTOM_FIELD":    "1",
+				"STAMP_DONE_STATUS":           "1222",
+				"SENTRY_DSN":                  "sentry",
+				"MAILGUN_API":                 "key",
+				"MAILGUN_DOMAIN":              "mg.google.com",
+			},
+			shouldFail: true,
+		},
 	}
 
 	for _, tt := range cases {
@@ -151,3 +538,46 @@ func Test_Current(t *testing.T) {
 		})
 	}
 }
+
+func TestMailgunRecipientsFor(t *testing.T) {
+	m := &Mailgun{
+		Recipients:        []string{"qa@google.com"},
+		ProjectRecipients: map[string]string{"ios": "ios@google.com; lead@google.com;", "android": ""},
+	}
+	cases := []struct {
+		project  string
+		expected []string
+	}{
+		{"ios", []string{"ios@google.com", "lead@google.com"}},
+		{"android", nil},
+		{"web", []string{"qa@google.com"}},
+	}
+
+	for _, tc := range cases {
+		if diff := cmp.Diff(m.RecipientsFor(tc.project), tc.expected); diff != "" {
+			t.Errorf("Wrong recipients for project %s, diff: %s", tc.project, diff)
+		}
+	}
+}
+
+func TestBuildFieldModeFor(t *testing.T) {
+	c := &Config{BuildFieldMode: "replace", ProjectBuildFieldModes: map[string]string{"ios": "list"}}
+	cases := map[string]string{"ios": "list", "android": "replace"}
+
+	for project, expected := range cases {
+		if received := c.BuildFieldModeFor(project); received != expected {
+			t.Errorf("Wrong build field mode for project %s, received: %s expected: %s", project, received, expected)
+		}
+	}
+}
+
+func TestSwitchUserFor(t *testing.T) {
+	c := &Config{SwitchUser: "release-bot", SwitchUsers: map[string]string{"manual-jdoe": "john"}}
+	cases := map[string]string{"manual-jdoe": "john", "webhook": "release-bot", "": "release-bot"}
+
+	for triggeredBy, expected := range cases {
+		if received := c.SwitchUserFor(triggeredBy); received != expected {
+			t.Errorf("Wrong switch user for %q, received: %s expected: %s", triggeredBy, received, expected)
+		}
+	}
+}
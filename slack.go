@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SlackNotifier posts build report to Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates notifier posting to Slack incoming webhook URL with client
+func NewSlackNotifier(webhookURL string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{webhookURL: webhookURL, client: client}
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string       `json:"type"`
+	Text   *slackText   `json:"text,omitempty"`
+	Fields []*slackText `json:"fields,omitempty"`
+}
+
+type slackMessage struct {
+	Text   string        `json:"text"`
+	Blocks []*slackBlock `json:"blocks"`
+}
+
+// Name returns notifier name
+func (s *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify posts Block Kit formatted report, reports without processed issues are skipped
+func (s *SlackNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	if len(report.Response.Success) == 0 && len(report.Response.Failures) == 0 {
+		return ErrNothingToNotify
+	}
+	body, err := json.Marshal(slackReportMessage(report))
+	if err != nil {
+		return err
+	}
+	if err = postJSON(ctx, s.client, s.webhookURL, body, nil); err != nil {
+		return fmt.Errorf("SlackNotifier: %w", err)
+	}
+	return nil
+}
+
+// NotifyDigest posts periodic project digest
+func (s *SlackNotifier) NotifyDigest(ctx context.Context, digest *Digest) error {
+	body, err := json.Marshal(slackDigestMessage(digest))
+	if err != nil {
+		return err
+	}
+	if err = postJSON(ctx, s.client, s.webhookURL, body, nil); err != nil {
+		return fmt.Errorf("SlackNotifier: %w", err)
+	}
+	return nil
+}
+
+func slackDigestMessage(digest *Digest) *slackMessage {
+	period := "Daily"
+	if digest.Weekly {
+		period = "Weekly"
+	}
+	message := &slackMessage{
+		Text: fmt.Sprintf("%s digest for %s: %d issues in %d builds", period, digest.Project, len(digest.Stamped), len(digest.Builds)),
+		Blocks: []*slackBlock{
+			{Type: "header", Text: &slackText{"plain_text", fmt.Sprintf("%s digest for %s", period, digest.Project)}},
+			{Type: "section", Fields: []*slackText{
+				{"mrkdwn", fmt.Sprintf("*Period:*\n%s - %s", digest.From.Format("2006-01-02 15:04"), digest.To.Format("2006-01-02 15:04 MST"))},
+				{"mrkdwn", fmt.Sprintf("*Builds:*\n%s", digest.BuildNumbers())},
+			}},
+		},
+	}
+	sections := []ReportSection{{"Stamped", digest.Stamped}, {"Failures", digest.Failed}}
+	for _, section := range sections {
+		if len(section.IDs) != 0 {
+			message.Blocks = append(message.Blocks, slackIssuesBlock(section, digest.IssueURL))
+		}
+	}
+	return message
+}
+
+func slackReportMessage(report *BuildReport) *slackMessage {
+	resp := report.Response
+	message := &slackMessage{
+		Text: fmt.Sprintf("Build %d: %d stamped, %d failed", report.BuildNumber, len(resp.Success), len(resp.Failures)),
+		Blocks: []*slackBlock{
+			{Type: "header", Text: &slackText{"plain_text", report.Subject()}},
+			{Type: "section", Fields: []*slackText{
+				{"mrkdwn", fmt.Sprintf("*Build number:*\n%d", report.BuildNumber)},
+				{"mrkdwn", fmt.Sprintf("*Version:*\n%s", report.Version)},
+			}},
+		},
+	}
+
+	for _, section := range report.Sections() {
+		message.Blocks = append(message.Blocks, slackIssuesBlock(section, report.IssueURL))
+	}
+	return message
+}
+
+func slackIssuesBlock(section ReportSection, issueURL func(id int) string) *slackBlock {
+	links := make([]string, 0, len(section.IDs))
+	for _, id := range section.IDs {
+		links = append(links, fmt.Sprintf("<%s|#%d>", issueURL(id), id))
+	}
+	return &slackBlock{
+		Type: "section",
+		Text: &slackText{"mrkdwn", fmt.Sprintf("*%s (%d):*\n%s", section.Title, len(section.IDs), strings.Join(links, "\n"))},
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSlackNotifierPostsBlockKitMessage(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Wrong content type: %s", r.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &received)
+	}))
+	defer server.Close()
+
+	report := &BuildReport{
+		Response:    &HookResponse{Success: []int{1, 2}, Failures: []int{3}},
+		RedmineHost: "https://redmine.org",
+		BuildNumber: 12,
+		Issues:      []*Issue{{ID: 1}},
+		Version:     "v2",
+	}
+	report.Issues[0].Project.Name = "App"
+	if err := NewSlackNotifier(server.URL, http.DefaultClient).Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"text": "Build 12: 2 stamped, 1 failed",
+		"blocks": []interface{}{
+			map[string]interface{}{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": "Redmine Hooks Results (App)"}},
+			map[string]interface{}{"type": "section", "fields": []interface{}{
+				map[string]interface{}{"type": "mrkdwn", "text": "*Build number:*\n12"},
+				map[string]interface{}{"type": "mrkdwn", "text": "*Version:*\nv2"},
+			}},
+			map[string]interface{}{"type": "section", "text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": "*Success (2):*\n<https://redmine.org/issues/1|#1>\n<https://redmine.org/issues/2|#2>",
+			}},
+			map[string]interface{}{"type": "section", "text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": "*Failures (1):*\n<https://redmine.org/issues/3|#3>",
+			}},
+		},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong Slack message, diff: %s", diff)
+	}
+}
+
+func TestSlackNotifierFailsOnWrongStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	report := &BuildReport{Response: &HookResponse{Success: []int{1}}}
+	if err := NewSlackNotifier(server.URL, http.DefaultClient).Notify(context.Background(), report); err == nil {
+		t.Error("Notify should fail on wrong status code")
+	}
+}
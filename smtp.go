@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+// SMTPMailer sends emails through SMTP relay
+type SMTPMailer struct {
+	settings  settings.SMTP
+	tlsConfig *tls.Config
+}
+
+// NewSMTPMailer creates mailer for the SMTP relay
+func NewSMTPMailer(settings settings.SMTP) *SMTPMailer {
+	return &SMTPMailer{settings: settings, tlsConfig: &tls.Config{ServerName: settings.Host, MinVersion: tls.VersionTLS12}}
+}
+
+// Send delivers multipart email with text and HTML alternatives
+func (s *SMTPMailer) Send(ctx context.Context, email *Email) error {
+	message, err := buildMIMEMessage(email)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.settings.Host, strconv.Itoa(s.settings.Port)))
+	if err != nil {
+		return fmt.Errorf("SMTPMailer: can't connect to relay: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.settings.Host)
+	if err != nil {
+		return fmt.Errorf("SMTPMailer: %w", err)
+	}
+	defer client.Close()
+
+	if s.settings.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTPMailer: relay doesn't support STARTTLS")
+		}
+		if err = client.StartTLS(s.tlsConfig); err != nil {
+			return fmt.Errorf("SMTPMailer: STARTTLS failed: %w", err)
+		}
+	}
+	if s.settings.Username != "" {
+		auth := smtp.PlainAuth("", s.settings.Username, s.settings.Password, s.settings.Host)
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTPMailer: authentication failed: %w", err)
+		}
+	}
+
+	if err = client.Mail(email.From); err != nil {
+		return fmt.Errorf("SMTPMailer: MAIL FROM failed: %w", err)
+	}
+	for _, recipient := range email.To {
+		if err = client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("SMTPMailer: RCPT TO %s failed: %w", recipient, err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTPMailer: DATA failed: %w", err)
+	}
+	if _, err = writer.Write(message); err != nil {
+		return fmt.Errorf("SMTPMailer: can't write message: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("SMTPMailer: message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+func buildMIMEMessage(email *Email) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	alternatives := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	}
+	for _, alternative := range alternatives {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(part)
+		if _, err = encoder.Write([]byte(alternative.content)); err != nil {
+			return nil, err
+		}
+		if err = encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", email.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(email.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
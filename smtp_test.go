@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeSMTPServer is a minimal in-process SMTP relay recording received sessions
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu         sync.Mutex
+	auth       string
+	from       string
+	recipients []string
+	data       string
+	tls        bool
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't start fake SMTP server: %s", err)
+	}
+	server := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeSMTPServer) settings() settings.SMTP {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return settings.SMTP{Host: host, Port: portNumber}
+}
+
+func (f *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = io.WriteString(conn, line+"\r\n")
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			if f.tlsConfig != nil {
+				reply("250-localhost")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-localhost")
+			}
+			reply("250 AUTH PLAIN")
+		case command == "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			f.mu.Lock()
+			f.tls = true
+			f.mu.Unlock()
+		case strings.HasPrefix(command, "AUTH PLAIN"):
+			f.mu.Lock()
+			f.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+			f.mu.Unlock()
+			reply("235 authenticated")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			f.mu.Lock()
+			f.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			f.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			f.mu.Lock()
+			f.recipients = append(f.recipients, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			f.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			f.mu.Lock()
+			f.data = data.String()
+			f.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func newTestTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}, MinVersion: tls.VersionTLS12}
+	client = &tls.Config{ServerName: "127.0.0.1", RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return server, client
+}
+
+func testEmail() *Email {
+	return &Email{
+		From:    "bot@example.com",
+		To:      []string{"qa@example.com", "pm@example.com"},
+		Subject: "Build 512 готов",
+		Text:    "Build number: 512",
+		HTML:    "<p>Build number: 512</p>",
+	}
+}
+
+func TestSMTPMailerSendsMultipartMessage(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	if err := NewSMTPMailer(server.settings()).Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.from != "bot@example.com" {
+		t.Errorf("Wrong sender: %s", server.from)
+	}
+	if diff := cmp.Diff(server.recipients, []string{"qa@example.com", "pm@example.com"}); diff != "" {
+		t.Errorf("Wrong recipients, diff: %s", diff)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("Can't parse message: %s", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if subject != "Build 512 готов" {
+		t.Errorf("Wrong subject: %s", subject)
+	}
+	mediaType, params, _ := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Wrong content type: %s", mediaType)
+	}
+
+	var parts []string
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			break
+		}
+		content, _ := io.ReadAll(quotedprintable.NewReader(part))
+		parts = append(parts, part.Header.Get("Content-Type")+": "+string(content))
+	}
+	expected := []string{
+		"text/plain; charset=utf-8: Build number: 512",
+		"text/html; charset=utf-8: <p>Build number: 512</p>",
+	}
+	if diff := cmp.Diff(parts, expected); diff != "" {
+		t.Errorf("Wrong message parts, diff: %s", diff)
+	}
+}
+
+func TestSMTPMailerStartTLSAndAuth(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	server := newFakeSMTPServer(t, serverTLS)
+	cfg := server.settings()
+	cfg.StartTLS = true
+	cfg.Username = "bot"
+	cfg.Password = "secret"
+	mailer := NewSMTPMailer(cfg)
+	mailer.tlsConfig = clientTLS
+
+	if err := mailer.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.tls {
+		t.Error("Session should be upgraded with STARTTLS")
+	}
+	if server.auth == "" {
+		t.Error("Mailer should authenticate")
+	}
+}
+
+func TestSMTPMailerRequiresStartTLSSupport(t *testing.T) {
+	server := newFakeSMTPServer(t, nil)
+	cfg := server.settings()
+	cfg.StartTLS = true
+
+	if err := NewSMTPMailer(cfg).Send(context.Background(), testEmail()); err == nil {
+		t.Error("Send should fail when relay doesn't support STARTTLS")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+// Snapshot modes define which issues are stamped when cached snapshot differs from the live query
+const (
+	SnapshotModeIntersection = "intersection"
+	SnapshotModeCached       = "cached"
+	SnapshotModeUnion        = "union"
+)
+
+// SnapshotDiff represents difference between issues cached on build start and issues queried on build finish
+type SnapshotDiff struct {
+	Common  []*Issue
+	Added   []*Issue
+	Removed []*Issue
+}
+
+func diffSnapshots(cached, fresh *IssuesContainer) *SnapshotDiff {
+	cachedIDs := make(map[int]bool, len(cached.Issues))
+	for _, issue := range cached.Issues {
+		cachedIDs[issue.ID] = true
+	}
+	freshIDs := make(map[int]bool, len(fresh.Issues))
+	for _, issue := range fresh.Issues {
+		freshIDs[issue.ID] = true
+	}
+
+	diff := new(SnapshotDiff)
+	for _, issue := range fresh.Issues {
+		if cachedIDs[issue.ID] {
+			diff.Common = append(diff.Common, issue)
+			continue
+		}
+		diff.Added = append(diff.Added, issue)
+	}
+	for _, issue := range cached.Issues {
+		if !freshIDs[issue.ID] {
+			diff.Removed = append(diff.Removed, issue)
+		}
+	}
+	return diff
+}
+
+// Stamped returns issues list which should be moved to done state in the mode
+func (d *SnapshotDiff) Stamped(mode string) *IssuesContainer {
+	var stamped []*Issue
+	switch mode {
+	case SnapshotModeCached:
+		stamped = append(stamped, d.Common...)
+		stamped = append(stamped, d.Removed...)
+	case SnapshotModeUnion:
+		stamped = append(stamped, d.Common...)
+		stamped = append(stamped, d.Added...)
+		stamped = append(stamped, d.Removed...)
+	default:
+		stamped = append(stamped, d.Common...)
+	}
+	return &IssuesContainer{stamped}
+}
+
+func issueIDs(issues []*Issue) []int {
+	ids := make([]int, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.ID)
+	}
+	return ids
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	cached := &IssuesContainer{[]*Issue{{ID: 1}, {ID: 2}, {ID: 3}}}
+	fresh := &IssuesContainer{[]*Issue{{ID: 4}, {ID: 2}, {ID: 1}}}
+
+	diff := diffSnapshots(cached, fresh)
+	if d := cmp.Diff(issueIDs(diff.Common), []int{2, 1}); d != "" {
+		t.Errorf("Wrong common issues, diff: %s", d)
+	}
+	if d := cmp.Diff(issueIDs(diff.Added), []int{4}); d != "" {
+		t.Errorf("Wrong added issues, diff: %s", d)
+	}
+	if d := cmp.Diff(issueIDs(diff.Removed), []int{3}); d != "" {
+		t.Errorf("Wrong removed issues, diff: %s", d)
+	}
+}
+
+func TestSnapshotDiffStamped(t *testing.T) {
+	diff := &SnapshotDiff{
+		Common:  []*Issue{{ID: 1}},
+		Added:   []*Issue{{ID: 2}},
+		Removed: []*Issue{{ID: 3}},
+	}
+	cases := []struct {
+		mode     string
+		expected []int
+	}{
+		{SnapshotModeIntersection, []int{1}},
+		{"", []int{1}},
+		{SnapshotModeCached, []int{1, 3}},
+		{SnapshotModeUnion, []int{1, 2, 3}},
+	}
+
+	for _, tc := range cases {
+		received := issueIDs(diff.Stamped(tc.mode).Issues)
+		if d := cmp.Diff(received, tc.expected); d != "" {
+			t.Errorf("Wrong stamped issues for mode %q, diff: %s", tc.mode, d)
+		}
+	}
+}
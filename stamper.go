@@ -1,26 +1,74 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"net/url"
 
 	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/getsentry/sentry-go"
 	"github.com/rs/zerolog"
 )
 
 // Stamper is a handler for moving ready to build tasks to done state
 type Stamper struct {
-	settings *settings.Config
-	rdb      Storage
+	settings  *settings.Config
+	client    *http.Client
+	rdb       Storage
+	history   *History
+	notifiers []Notifier
+	outbox    *Outbox
+	versions  *VersionManager
+	wiki      *WikiPublisher
+	links     *BuildTemplate
+	values    *BuildTemplate
+	bitrise   *BitriseClient
+	filters   url.Values
 }
 
-// NewStamper creates handler class configured by settings and connected to redis client
-func NewStamper(settings *settings.Config, storage Storage) *Stamper {
-	return &Stamper{settings: settings, rdb: storage}
+// NewStamper creates handler class configured by settings and connected to storage,
+// Redmine is accessed with client and build results are delivered with every passed notifier
+func NewStamper(settings *settings.Config, client *http.Client, storage Storage, notifiers ...Notifier) *Stamper {
+	return &Stamper{settings: settings, client: client, rdb: storage, history: NewHistory(storage), notifiers: notifiers}
+}
+
+// SetVersions enables assigning stamped issues to the build Redmine Version
+func (s *Stamper) SetVersions(versions *VersionManager) {
+	s.versions = versions
+}
+
+// SetWiki enables publishing release notes of stamped issues to Redmine wiki
+func (s *Stamper) SetWiki(wiki *WikiPublisher) {
+	s.wiki = wiki
+}
+
+// SetBuildLinks enables writing build download page into stamped issues
+func (s *Stamper) SetBuildLinks(links *BuildTemplate) {
+	s.links = links
+}
+
+// SetBuildValues enables rendering build custom field value instead of the bare build number
+func (s *Stamper) SetBuildValues(values *BuildTemplate) {
+	s.values = values
+}
+
+// SetBitrise enables fetching finished build details from Bitrise API
+func (s *Stamper) SetBitrise(bitrise *BitriseClient) {
+	s.bitrise = bitrise
+}
+
+// SetIssueFilters sets default Redmine issues filters, REDMINE_FILTERS request header overrides them by key
+func (s *Stamper) SetIssueFilters(filters url.Values) {
+	s.filters = filters
+}
+
+// SetOutbox enables retries of failed notifications through outbox
+func (s *Stamper) SetOutbox(outbox *Outbox) {
+	s.outbox = outbox
 }
 
 func (s *Stamper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +88,27 @@ func (s *Stamper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	logger = logger.With().Str("r_project", projectID).Logger()
 
-	resp, statusCode, err := s.handleEvent(r.WithContext(logger.WithContext(r.Context())), projectID)
+	query, err := NewIssueQuery(projectID, s.filters)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("wrong incoming headers")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if raw := r.Header.Get("REDMINE_FILTERS"); raw != "" {
+		filters, err := ParseIssueFilters(raw)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Msg("wrong incoming headers")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Filters = mergeIssueFilters(s.filters, filters)
+	}
+
+	resp, statusCode, err := s.handleEvent(r.WithContext(logger.WithContext(r.Context())), query)
 	logger.Debug().
 		Int("status code", statusCode).
 		Msg("create a new response")
@@ -60,7 +128,7 @@ func (s *Stamper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-func (s *Stamper) handleEvent(r *http.Request, projectID string) (*HookResponse, int, error) {
+func (s *Stamper) handleEvent(r *http.Request, query *IssueQuery) (*HookResponse, int, error) {
 	payload, err := s.readAndParsePayload(r)
 	if err != nil {
 		return nil, http.StatusBadRequest, err
@@ -74,20 +142,22 @@ func (s *Stamper) handleEvent(r *http.Request, projectID string) (*HookResponse,
 		Msg("received bitrise event header")
 	switch et {
 	case "build/triggered":
-		return s.handleTriggeredEvent(payload, projectID)
+		return s.handleTriggeredEvent(r.Context(), payload, query)
+	case "build/heartbeat":
+		return s.handleHeartbeatEvent(r.Context(), payload, query)
 	case "build/finished":
-		return s.handleFinishedEvent(payload, projectID)
+		return s.handleFinishedEvent(r.Context(), payload, query)
 	default:
 		return nil, http.StatusOK, fmt.Errorf("handleEvent: unsupported bitrise event type %s", et)
 	}
 }
 
-func (s *Stamper) handleTriggeredEvent(payload *HookPayload, redmineProject string) (*HookResponse, int, error) {
+func (s *Stamper) handleTriggeredEvent(ctx context.Context, payload *HookPayload, query *IssueQuery) (*HookResponse, int, error) {
 	if err := payload.ValidateInternal(); err != nil {
 		return nil, http.StatusOK, err
 	}
 
-	iContainer, err := issues(s.settings, redmineProject)
+	iContainer, err := issues(ctx, s.client, s.settings, query)
 	if err != nil {
 		return nil, http.StatusBadRequest, fmt.Errorf("handleTriggeredEvent: wrong error from server: %s", err)
 	}
@@ -96,43 +166,239 @@ func (s *Stamper) handleTriggeredEvent(payload *HookPayload, redmineProject stri
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("handleTriggeredEvent: can't serialize data to string: %s", err)
 	}
-	err = s.rdb.Set(payload.BuildSlug, data, 4*time.Hour).Err()
+	ttl := s.settings.CacheTTLFor(query.Project)
+	err = s.rdb.Set(ctx, payload.BuildSlug, data, ttl)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("handleTriggeredEvent: can't write new cache with build: %+v\nerror: %s", payload, err)
 	}
+	zerolog.Ctx(ctx).
+		Debug().
+		Str("build slug", payload.BuildSlug).
+		Dur("ttl", ttl).
+		Msg("issues snapshot cached")
+	if err = s.history.Triggered(ctx, payload, query.Project); err != nil {
+		zerolog.Ctx(ctx).
+			Error().
+			Err(err).
+			Msg("can't record triggered build in history")
+	}
 
 	var logItems []int
 	for _, issue := range iContainer.Issues {
 		logItems = append(logItems, issue.ID)
 	}
-	return &HookResponse{fmt.Sprintf("Caching issue data was completed (Build: %s)", payload.BuildSlug), logItems, []int{}}, http.StatusOK, nil
+	return &HookResponse{Message: fmt.Sprintf("Caching issue data was completed (Build: %s)", payload.BuildSlug), Success: logItems, Failures: []int{}}, http.StatusOK, nil
 }
 
-func (s *Stamper) handleFinishedEvent(payload *HookPayload, redmineProject string) (*HookResponse, int, error) {
+func (s *Stamper) handleHeartbeatEvent(ctx context.Context, payload *HookPayload, query *IssueQuery) (*HookResponse, int, error) {
+	if err := payload.ValidateInternal(); err != nil {
+		return nil, http.StatusOK, err
+	}
+
+	cached, err := s.rdb.Get(ctx, payload.BuildSlug)
+	if err != nil {
+		return nil, http.StatusOK, fmt.Errorf("handleHeartbeatEvent: no issues snapshot to refresh (Build: %s): %w", payload.BuildSlug, err)
+	}
+
+	ttl := s.settings.CacheTTLFor(query.Project)
+	if err = s.rdb.Set(ctx, payload.BuildSlug, cached, ttl); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("handleHeartbeatEvent: can't refresh cache with build: %+v\nerror: %s", payload, err)
+	}
+	zerolog.Ctx(ctx).
+		Debug().
+		Str("build slug", payload.BuildSlug).
+		Dur("ttl", ttl).
+		Msg("issues snapshot lifetime refreshed")
+
+	return NewResponse(fmt.Sprintf("Issues snapshot lifetime was refreshed (Build: %s)", payload.BuildSlug)), http.StatusOK, nil
+}
+
+func (s *Stamper) handleFinishedEvent(ctx context.Context, payload *HookPayload, query *IssueQuery) (*HookResponse, int, error) {
 	if err := payload.ValidateInternalAndSuccess(); err != nil {
 		return nil, http.StatusOK, err
 	}
+	if s.bitrise != nil && payload.AppSlug != "" {
+		details, err := s.bitrise.BuildDetails(ctx, payload.AppSlug, payload.BuildSlug)
+		if err != nil {
+			zerolog.Ctx(ctx).
+				Warn().
+				Err(err).
+				Str("build slug", payload.BuildSlug).
+				Msg("can't fetch build details from Bitrise")
+			sentry.CaptureException(err)
+		}
+		payload.Details = details
+	}
 
-	cached, err := s.rdb.Get(payload.BuildSlug).Result()
+	cached, err := s.rdb.Get(ctx, payload.BuildSlug)
 	var issuesList *IssuesContainer
+	var cache *CacheInfo
 	version := "v2"
 	if err != nil {
-		issuesList, err = issues(s.settings, redmineProject)
+		cache = &CacheInfo{Status: CacheStatusFallback, Reason: err.Error()}
+		zerolog.Ctx(ctx).
+			Warn().
+			Err(err).
+			Str("build slug", payload.BuildSlug).
+			Msg("issues snapshot is unavailable, falling back to live query")
+		issuesList, err = issues(ctx, s.client, s.settings, query)
 		if err != nil {
 			return nil, http.StatusBadRequest, fmt.Errorf("handleFinishedEvent: wrong error from server: %w", err)
 		}
 	} else {
+		cache = &CacheInfo{Status: CacheStatusHit}
 		version += " cached"
 		issuesList = new(IssuesContainer)
-		_ = json.Unmarshal([]byte(cached), issuesList)
+		_ = json.Unmarshal(cached, issuesList)
 	}
 
-	response := batchTransaction(RedmineDoneMarker{}, issuesList, s.settings, payload.BuildNumber)
-	_ = sendMailgunNotification(response, s.settings.Host, payload.BuildNumber, issuesList.Issues, version)
+	var diff *SnapshotDiff
+	if cache.Status == CacheStatusHit {
+		fresh, err := issues(ctx, s.client, s.settings, query)
+		if err != nil {
+			zerolog.Ctx(ctx).
+				Warn().
+				Err(err).
+				Str("build slug", payload.BuildSlug).
+				Msg("live query for snapshot diff failed, using cached snapshot verbatim")
+		} else {
+			diff = diffSnapshots(issuesList, fresh)
+			issuesList = diff.Stamped(s.settings.SnapshotMode)
+			zerolog.Ctx(ctx).
+				Debug().
+				Ints("added during build", issueIDs(diff.Added)).
+				Ints("removed during build", issueIDs(diff.Removed)).
+				Str("mode", s.settings.SnapshotMode).
+				Msg("issues snapshot compared with live query")
+		}
+	}
+
+	stamp := &Stamp{BuildNumber: payload.BuildNumber, BuildFieldMode: s.settings.BuildFieldModeFor(query.Project)}
+	triggeredBy := ""
+	if payload.Details != nil {
+		triggeredBy = payload.Details.TriggeredBy
+	}
+	stamp.SwitchUser = s.settings.SwitchUserFor(triggeredBy)
+	var fixedVersion *Version
+	if s.versions != nil && len(issuesList.Issues) != 0 {
+		if fixedVersion, stamp.VersionIDs, err = s.versions.EnsureForIssues(ctx, query.Project, issuesList.Issues, payload); err != nil {
+			zerolog.Ctx(ctx).
+				Error().
+				Err(err).
+				Msg("can't prepare build version, stamping affected issues without it")
+			sentry.CaptureException(err)
+		}
+	}
+
+	if s.values != nil {
+		if stamp.BuildValue, err = s.values.Render(query.Project, payload); err != nil {
+			zerolog.Ctx(ctx).
+				Error().
+				Err(err).
+				Msg("can't render build field value, stamping build number")
+			sentry.CaptureException(err)
+		}
+	}
+	if s.links != nil {
+		if stamp.Link, err = s.links.Render(query.Project, payload); err != nil {
+			zerolog.Ctx(ctx).
+				Error().
+				Err(err).
+				Msg("can't prepare build link, stamping without it")
+			sentry.CaptureException(err)
+		}
+	}
+
+	response := batchTransaction(ctx, RedmineDoneMarker{client: s.client}, issuesList, s.settings, stamp)
+	response.Cache = cache
+	response.FixedVersion = fixedVersion
+	if diff != nil {
+		response.AddedDuringBuild = issueIDs(diff.Added)
+		response.RemovedDuringBuild = issueIDs(diff.Removed)
+	}
+	if err = s.history.Finished(ctx, payload, query.Project, response); err != nil {
+		zerolog.Ctx(ctx).
+			Error().
+			Err(err).
+			Msg("can't record finished build in history")
+	}
+	report := &BuildReport{
+		Response:     response,
+		RedmineHost:  s.settings.Host,
+		Project:      query.Project,
+		BuildNumber:  payload.BuildNumber,
+		BuildSlug:    payload.BuildSlug,
+		Workflow:     payload.BuildTriggeredWorkflow,
+		Issues:       issuesList.Issues,
+		Version:      version,
+		SnapshotMode: s.settings.SnapshotMode,
+	}
+	if diff != nil {
+		report.Issues = append(append(append([]*Issue{}, diff.Common...), diff.Added...), diff.Removed...)
+	}
+	if len(response.Success) != 0 {
+		notes := NewReleaseNotes(query.Project, payload.BuildNumber, s.settings.Host, report.Issues, response.Success)
+		if response.ReleaseNotes, err = notes.Markdown(); err != nil {
+			zerolog.Ctx(ctx).
+				Error().
+				Err(err).
+				Msg("can't render release notes")
+		}
+		if s.wiki != nil {
+			if response.WikiPage, err = s.wiki.Publish(ctx, query.Project, payload, notes, fixedVersion); err != nil {
+				zerolog.Ctx(ctx).
+					Error().
+					Err(err).
+					Msg("can't publish build wiki page")
+				sentry.CaptureException(err)
+			}
+		}
+	}
+	response.Notifications = s.notify(ctx, report)
 
 	return response, http.StatusOK, nil
 }
 
+func (s *Stamper) notify(ctx context.Context, report *BuildReport) []*NotificationResult {
+	results := make([]*NotificationResult, 0, len(s.notifiers))
+	for _, notifier := range s.notifiers {
+		result := &NotificationResult{Notifier: notifier.Name(), Status: NotificationDelivered}
+		results = append(results, result)
+
+		err := notifier.Notify(ctx, report)
+		if err == nil {
+			continue
+		}
+		result.Error = err.Error()
+		if errors.Is(err, ErrNothingToNotify) {
+			result.Status = NotificationSkipped
+			continue
+		}
+		if errors.Is(err, ErrPartialDelivery) {
+			result.Status = NotificationPartial
+			zerolog.Ctx(ctx).
+				Warn().
+				Err(err).
+				Str("notifier", notifier.Name()).
+				Msg("notification partially delivered")
+			continue
+		}
+
+		result.Status = NotificationFailed
+		if s.outbox != nil && s.outbox.Enqueue(notifier, report) {
+			result.Status = NotificationQueued
+		}
+		zerolog.Ctx(ctx).
+			Error().
+			Err(err).
+			Str("notifier", notifier.Name()).
+			Str("status", result.Status).
+			Msg("notification delivery failed")
+		sentry.CaptureException(err)
+	}
+	return results
+}
+
 func (s *Stamper) readAndParsePayload(r *http.Request) (*HookPayload, error) {
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
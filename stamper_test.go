@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestStamperRequestRedmineProjectKeyCheckFailure(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "", nil)
 	rw := httptest.NewRecorder()
-	handler := NewStamper(nil, nil)
+	handler := NewStamper(nil, http.DefaultClient, nil)
 	handler.ServeHTTP(rw, req)
 	if rw.Result().StatusCode != http.StatusBadRequest {
 		t.Errorf("Response status code should be 400 on failure, received %d", rw.Result().StatusCode)
@@ -20,7 +27,7 @@ func TestStamperRequestBadPayloadCheckFailure(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "", badBody{})
 	req.Header.Set("REDMINE_PROJECT", "11")
 	rw := httptest.NewRecorder()
-	handler := NewStamper(nil, nil)
+	handler := NewStamper(nil, http.DefaultClient, nil)
 	handler.ServeHTTP(rw, req)
 	if rw.Result().StatusCode != http.StatusBadRequest {
 		t.Errorf("Response status code should be 400 on bad payload, received %d", rw.Result().StatusCode)
@@ -31,7 +38,7 @@ func TestStamperRequestRedmineProjectKeyCheckSuccess(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodGet, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_number":12}`))
 	req.Header.Set("REDMINE_PROJECT", "11")
 	rw := httptest.NewRecorder()
-	handler := NewStamper(nil, nil)
+	handler := NewStamper(nil, http.DefaultClient, nil)
 	handler.ServeHTTP(rw, req)
 	if rw.Result().StatusCode != http.StatusOK {
 		t.Errorf("Response status code should be 200 on success, received %d", rw.Result().StatusCode)
@@ -43,7 +50,7 @@ func TestStamperRequestTriggeredEventNonInternal(t *testing.T) {
 	req.Header.Set("REDMINE_PROJECT", "11")
 	req.Header.Set("Bitrise-Event-Type", "build/triggered")
 	rw := httptest.NewRecorder()
-	handler := NewStamper(nil, nil)
+	handler := NewStamper(nil, http.DefaultClient, nil)
 	handler.ServeHTTP(rw, req)
 	if rw.Result().StatusCode != http.StatusOK {
 		t.Errorf("Response status code should be 200 on success, received %d", rw.Result().StatusCode)
@@ -55,3 +62,145 @@ func TestStamperRequestTriggeredEventNonInternal(t *testing.T) {
 		t.Errorf("Response body message wrong\nreceived: %q\nexpected: %q", resp, expected)
 	}
 }
+
+func TestStamperHeartbeatRefreshesSnapshot(t *testing.T) {
+	storage := newMemoryStorage()
+	clock := &fakeClock{time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+	storage.now = clock.Now
+	_ = storage.Set(context.Background(), "slug", []byte(`{"issues":[]}`), time.Hour)
+	s := &settings.Config{CacheTTL: time.Hour}
+
+	clock.Advance(50 * time.Minute)
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_slug":"slug"}`))
+	req.Header.Set("REDMINE_PROJECT", "11")
+	req.Header.Set("Bitrise-Event-Type", "build/heartbeat")
+	rw := httptest.NewRecorder()
+	NewStamper(s, http.DefaultClient, storage).ServeHTTP(rw, req)
+	if rw.Result().StatusCode != http.StatusOK {
+		t.Fatalf("Response status code should be 200 on success, received %d", rw.Result().StatusCode)
+	}
+
+	clock.Advance(50 * time.Minute)
+	if _, err := storage.Get(context.Background(), "slug"); err != nil {
+		t.Errorf("Snapshot lifetime should be refreshed by heartbeat, received: %s", err)
+	}
+}
+
+func TestStamperHeartbeatWithoutSnapshot(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_slug":"slug"}`))
+	req.Header.Set("REDMINE_PROJECT", "11")
+	req.Header.Set("Bitrise-Event-Type", "build/heartbeat")
+	rw := httptest.NewRecorder()
+	NewStamper(&settings.Config{CacheTTL: time.Hour}, http.DefaultClient, newMemoryStorage()).ServeHTTP(rw, req)
+	if rw.Result().StatusCode != http.StatusOK {
+		t.Errorf("Response status code should be 200 on missing snapshot, received %d", rw.Result().StatusCode)
+	}
+}
+
+func TestStamperFinishedEventReportsCacheFallback(t *testing.T) {
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"issues":[]}`))
+	}))
+	defer redmine.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_slug":"slug"}`))
+	req.Header.Set("REDMINE_PROJECT", "11")
+	req.Header.Set("Bitrise-Event-Type", "build/finished")
+	rw := httptest.NewRecorder()
+	NewStamper(&settings.Config{Host: redmine.URL}, http.DefaultClient, newMemoryStorage()).ServeHTTP(rw, req)
+	if rw.Result().StatusCode != http.StatusOK {
+		t.Fatalf("Response status code should be 200 on success, received %d", rw.Result().StatusCode)
+	}
+
+	resp := new(HookResponse)
+	if err := json.NewDecoder(rw.Body).Decode(resp); err != nil {
+		t.Fatalf("Can't decode response: %s", err)
+	}
+	if resp.Cache == nil || resp.Cache.Status != CacheStatusFallback {
+		t.Errorf("Response should report cache fallback, received: %+v", resp.Cache)
+	}
+}
+
+func TestStamperFinishedEventStampsSnapshotIntersection(t *testing.T) {
+	var stamped []string
+	var mu sync.Mutex
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mu.Lock()
+			stamped = append(stamped, r.URL.Path)
+			mu.Unlock()
+			return
+		}
+		_, _ = w.Write([]byte(`{"issues":[{"id":2},{"id":3}]}`))
+	}))
+	defer redmine.Close()
+
+	storage := newMemoryStorage()
+	_ = storage.Set(context.Background(), "slug", []byte(`{"issues":[{"id":1},{"id":2}]}`), time.Hour)
+
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_slug":"slug"}`))
+	req.Header.Set("REDMINE_PROJECT", "11")
+	req.Header.Set("Bitrise-Event-Type", "build/finished")
+	rw := httptest.NewRecorder()
+	NewStamper(&settings.Config{Host: redmine.URL, SnapshotMode: SnapshotModeIntersection}, http.DefaultClient, storage).ServeHTTP(rw, req)
+
+	resp := new(HookResponse)
+	if err := json.NewDecoder(rw.Body).Decode(resp); err != nil {
+		t.Fatalf("Can't decode response: %s", err)
+	}
+	expected := &HookResponse{
+		Message:            "Successful completed task",
+		Success:            []int{2},
+		Failures:           []int{},
+		Cache:              &CacheInfo{Status: CacheStatusHit},
+		AddedDuringBuild:   []int{3},
+		RemovedDuringBuild: []int{1},
+		ReleaseNotes:       "# 11 build 0\n\n## Other\n\n- (#2)\n",
+	}
+	if diff := cmp.Diff(resp, expected); diff != "" {
+		t.Errorf("Wrong finished event response, diff: %s", diff)
+	}
+	if diff := cmp.Diff(stamped, []string{"/issues/2.json"}); diff != "" {
+		t.Errorf("Only issues from both snapshots should be stamped, diff: %s", diff)
+	}
+}
+
+type skippingNotifier struct{}
+
+func (skippingNotifier) Name() string {
+	return "skipping"
+}
+
+func (skippingNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	return ErrNothingToNotify
+}
+
+func TestStamperReportsNotificationResults(t *testing.T) {
+	stamper := NewStamper(&settings.Config{}, http.DefaultClient, nil,
+		&mockNotifier{name: "delivered"},
+		&mockNotifier{name: "failed", failures: 1},
+		skippingNotifier{},
+	)
+	received := stamper.notify(context.Background(), &BuildReport{Response: NewResponse("")})
+	expected := []*NotificationResult{
+		{Notifier: "delivered", Status: NotificationDelivered},
+		{Notifier: "failed", Status: NotificationFailed, Error: "delivery failed"},
+		{Notifier: "skipping", Status: NotificationSkipped, Error: ErrNothingToNotify.Error()},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong notification results, diff: %s", diff)
+	}
+}
+
+func TestStamperQueuesFailedNotifications(t *testing.T) {
+	stamper := NewStamper(&settings.Config{}, http.DefaultClient, nil, &mockNotifier{name: "failed", failures: 1})
+	stamper.SetOutbox(NewOutbox(1, time.Hour))
+
+	received := stamper.notify(context.Background(), &BuildReport{Response: NewResponse("")})
+	expected := []*NotificationResult{
+		{Notifier: "failed", Status: NotificationQueued, Error: "delivery failed"},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong notification results, diff: %s", diff)
+	}
+}
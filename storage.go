@@ -1,13 +1,46 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/go-redis/redis"
+	"github.com/alphatroya/ci-redmine-bindings/settings"
 )
 
+// storageSweepInterval limits how often Set removes expired keys of embedded backends,
+// snapshots of failed builds are never read again so they can't rely on Get cleanup
+const storageSweepInterval = time.Minute
+
+// ErrNotFound is returned by Storage when the requested key is absent or expired
+var ErrNotFound = errors.New("storage: key not found")
+
 // Storage represents interface for storing data in external vault
 type Storage interface {
-	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
-	Get(key string) *redis.StringCmd
+	// Set stores value by key, zero expiration means the value never expires
+	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	// Get returns stored value or ErrNotFound if key is missing
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes value by key, missing key isn't an error
+	Delete(ctx context.Context, key string) error
+	// Append atomically adds value to the end of the list by key and keeps only limit
+	// latest values, zero limit keeps all of them. Values dropped from the list are returned.
+	// Lists never expire
+	Append(ctx context.Context, key string, value []byte, limit int) ([][]byte, error)
+	// List returns values of the list by key, the earliest value goes first, missing list is empty
+	List(ctx context.Context, key string) ([][]byte, error)
+}
+
+func createStorage(ctx context.Context, settings *settings.Config) (Storage, error) {
+	switch settings.StorageBackend {
+	case "redis":
+		return newRedisStorage(ctx, settings)
+	case "memory":
+		return newMemoryStorage(), nil
+	case "bolt":
+		return newBoltStorage(settings.BoltPath)
+	default:
+		return nil, fmt.Errorf("createStorage: unsupported storage backend %q", settings.StorageBackend)
+	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltBucket     = []byte("hook")
+	boltListBucket = []byte("hook-lists")
+)
+
+// BoltStorage keeps data in embedded BoltDB file
+type BoltStorage struct {
+	db  *bolt.DB
+	now func() time.Time
+	// sweptAt is the last time expired records were removed, it's guarded by BoltDB writer lock
+	sweptAt time.Time
+}
+
+func newBoltStorage(path string) (*BoltStorage, error) {
+	if path == "" {
+		return nil, errors.New("newBoltStorage: BOLT_PATH is required for bolt storage backend")
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltListBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltStorage{db: db, now: time.Now}, nil
+}
+
+// Set stores value by key in BoltDB, the first 8 bytes of record keep expiration time.
+// Expired records are swept in the same transaction from time to time
+func (b *BoltStorage) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := b.now()
+	var expiresAt int64
+	if expiration > 0 {
+		expiresAt = now.Add(expiration).UnixNano()
+	}
+	record := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(record, uint64(expiresAt))
+	copy(record[8:], value)
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if now.Sub(b.sweptAt) >= storageSweepInterval {
+			cursor := bucket.Cursor()
+			for k, v := cursor.First(); k != nil; {
+				if len(v) >= 8 && b.isExpired(v) {
+					if err := cursor.Delete(); err != nil {
+						return err
+					}
+					// deleted key moves cursor to the next one
+					k, v = cursor.Seek(k)
+					continue
+				}
+				k, v = cursor.Next()
+			}
+			b.sweptAt = now
+		}
+		return bucket.Put([]byte(key), record)
+	})
+}
+
+// Get reads value by key from BoltDB
+func (b *BoltStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var value []byte
+	expired := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket(boltBucket).Get([]byte(key))
+		if len(record) < 8 {
+			return ErrNotFound
+		}
+		if b.isExpired(record) {
+			expired = true
+			return ErrNotFound
+		}
+		value = append([]byte(nil), record[8:]...)
+		return nil
+	})
+	if expired {
+		_ = b.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(boltBucket)
+			if record := bucket.Get([]byte(key)); len(record) >= 8 && b.isExpired(record) {
+				return bucket.Delete([]byte(key))
+			}
+			return nil
+		})
+	}
+	return value, err
+}
+
+// Delete removes value by key from BoltDB
+func (b *BoltStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+// Append adds value to the list by key in BoltDB, lists are kept as JSON arrays in a separate bucket
+func (b *BoltStorage) Append(ctx context.Context, key string, value []byte, limit int) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var dropped [][]byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltListBucket)
+		var list [][]byte
+		if data := bucket.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, &list); err != nil {
+				return err
+			}
+		}
+		list = append(list, value)
+		if limit > 0 && len(list) > limit {
+			dropped = list[:len(list)-limit]
+			list = list[len(list)-limit:]
+		}
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dropped, nil
+}
+
+// List reads list values by key from BoltDB
+func (b *BoltStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	list := [][]byte{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(boltListBucket).Get([]byte(key)); data != nil {
+			return json.Unmarshal(data, &list)
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (b *BoltStorage) isExpired(record []byte) bool {
+	expiresAt := int64(binary.BigEndian.Uint64(record))
+	return expiresAt != 0 && b.now().UnixNano() >= expiresAt
+}
+
+// Close releases BoltDB file lock
+func (b *BoltStorage) Close() error {
+	return b.db.Close()
+}
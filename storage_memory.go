@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps data in process memory, all data is lost on restart
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+	lists map[string][][]byte
+	now   func() time.Time
+	// sweptAt is the last time expired items were removed
+	sweptAt time.Time
+}
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{items: make(map[string]memoryItem), lists: make(map[string][][]byte), now: time.Now}
+}
+
+// Set stores value by key in memory, expired items are swept from time to time
+func (m *MemoryStorage) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := m.now()
+	item := memoryItem{value: append([]byte(nil), value...)}
+	if expiration > 0 {
+		item.expiresAt = now.Add(expiration)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.sweptAt) >= storageSweepInterval {
+		for existing, stored := range m.items {
+			if !stored.expiresAt.IsZero() && !now.Before(stored.expiresAt) {
+				delete(m.items, existing)
+			}
+		}
+		m.sweptAt = now
+	}
+	m.items[key] = item
+	return nil
+}
+
+// Get reads value by key from memory
+func (m *MemoryStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	item, ok := m.items[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !item.expiresAt.IsZero() && !m.now().Before(item.expiresAt) {
+		m.mu.Lock()
+		// the key may be set again between the locks, so expiration is checked once more
+		if current, ok := m.items[key]; ok && !current.expiresAt.IsZero() && !m.now().Before(current.expiresAt) {
+			delete(m.items, key)
+		}
+		m.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), item.value...), nil
+}
+
+// Delete removes value by key from memory
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// Append adds value to the list by key in memory
+func (m *MemoryStorage) Append(ctx context.Context, key string, value []byte, limit int) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := append(m.lists[key], append([]byte(nil), value...))
+	var dropped [][]byte
+	if limit > 0 && len(list) > limit {
+		dropped = list[:len(list)-limit]
+		list = append([][]byte(nil), list[len(list)-limit:]...)
+	}
+	m.lists[key] = list
+	return dropped, nil
+}
+
+// List reads list values by key from memory
+func (m *MemoryStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	values := make([][]byte, 0, len(m.lists[key]))
+	for _, value := range m.lists[key] {
+		values = append(values, append([]byte(nil), value...))
+	}
+	return values, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStorage keeps data in Redis server, standalone, Sentinel and Cluster setups are supported
+type RedisStorage struct {
+	client  redis.UniversalClient
+	timeout time.Duration
+}
+
+func newRedisStorage(ctx context.Context, settings *settings.Config) (*RedisStorage, error) {
+	client, err := newRedisClient(settings.RedisURL, settings.RedisMode)
+	if err != nil {
+		return nil, err
+	}
+	storage := &RedisStorage{client: client, timeout: settings.RedisTimeout}
+
+	ctx, cancel := storage.withTimeout(ctx)
+	defer cancel()
+	if err = client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("newRedisStorage: can't reach redis server: %w", err)
+	}
+	return storage, nil
+}
+
+// newRedisClient creates client for the connection mode, rediss:// scheme enables TLS in all modes
+func newRedisClient(url, mode string) (redis.UniversalClient, error) {
+	if url == "" {
+		return nil, errors.New("newRedisClient: REDIS_URL is required for redis storage backend")
+	}
+	switch mode {
+	case "", "standalone":
+		options, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClient(options), nil
+	case "sentinel":
+		options, err := redis.ParseFailoverURL(url)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewFailoverClient(options), nil
+	case "cluster":
+		options, err := redis.ParseClusterURL(url)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClusterClient(options), nil
+	default:
+		return nil, fmt.Errorf("newRedisClient: unsupported redis mode %q", mode)
+	}
+}
+
+// Set stores value by key in Redis
+func (r *RedisStorage) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.client.Set(ctx, key, value, expiration).Err()
+}
+
+// Get reads value by key from Redis
+func (r *RedisStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// redisAppendScript pushes value to the list and trims it returning dropped values
+var redisAppendScript = redis.NewScript(`
+local size = redis.call("RPUSH", KEYS[1], ARGV[1])
+local limit = tonumber(ARGV[2])
+if limit <= 0 or size <= limit then
+	return {}
+end
+local dropped = redis.call("LRANGE", KEYS[1], 0, size - limit - 1)
+redis.call("LTRIM", KEYS[1], -limit, -1)
+return dropped
+`)
+
+// Delete removes value by key from Redis
+func (r *RedisStorage) Delete(ctx context.Context, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.client.Del(ctx, key).Err()
+}
+
+// Append pushes value to the Redis list by key and trims it to limit latest values in one script call
+func (r *RedisStorage) Append(ctx context.Context, key string, value []byte, limit int) ([][]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	items, err := redisAppendScript.Run(ctx, r.client, []string{key}, value, limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	var dropped [][]byte
+	for _, item := range items {
+		dropped = append(dropped, []byte(item))
+	}
+	return dropped, nil
+}
+
+// List reads Redis list values by key
+func (r *RedisStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	items, err := r.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0, len(items))
+	for _, item := range items {
+		values = append(values, []byte(item))
+	}
+	return values, nil
+}
+
+// Close closes underlying redis connections
+func (r *RedisStorage) Close() error {
+	return r.client.Close()
+}
+
+func (r *RedisStorage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
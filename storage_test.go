@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/go-redis/v9"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMemoryStorageConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration), func(string) bool) {
+		storage := newMemoryStorage()
+		clock := &fakeClock{time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+		storage.now = clock.Now
+		stored := func(key string) bool {
+			storage.mu.RLock()
+			defer storage.mu.RUnlock()
+			_, ok := storage.items[key]
+			return ok
+		}
+		return storage, clock.Advance, stored
+	})
+}
+
+func TestMemoryStorageKeepsValueSetDuringExpiration(t *testing.T) {
+	ctx := context.Background()
+	storage := newMemoryStorage()
+	clock := &fakeClock{time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+	storage.now = clock.Now
+	_ = storage.Set(ctx, "key", []byte("stale"), time.Hour)
+	clock.Advance(time.Hour)
+
+	refreshed := false
+	storage.now = func() time.Time {
+		// another request stores a fresh value right after the expired one is read
+		if !refreshed {
+			refreshed = true
+			_ = storage.Set(ctx, "key", []byte("fresh"), 0)
+		}
+		return clock.Now()
+	}
+	if _, err := storage.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expired value should return ErrNotFound, received: %v", err)
+	}
+	value, err := storage.Get(ctx, "key")
+	if err != nil || !bytes.Equal(value, []byte("fresh")) {
+		t.Errorf("Fresh value should survive expiration of the stale one, received: %q, %v", value, err)
+	}
+}
+
+func TestBoltStorageConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration), func(string) bool) {
+		storage, err := newBoltStorage(filepath.Join(t.TempDir(), "hook.db"))
+		if err != nil {
+			t.Fatalf("Can't open bolt storage: %s", err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+		clock := &fakeClock{time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)}
+		storage.now = clock.Now
+		stored := func(key string) bool {
+			var ok bool
+			_ = storage.db.View(func(tx *bolt.Tx) error {
+				ok = tx.Bucket(boltBucket).Get([]byte(key)) != nil
+				return nil
+			})
+			return ok
+		}
+		return storage, clock.Advance, stored
+	})
+}
+
+func TestRedisStorageConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration), func(string) bool) {
+		server := miniredis.RunT(t)
+		storage, err := newRedisStorage(context.Background(), &settings.Config{
+			RedisURL:     "redis://" + server.Addr(),
+			RedisMode:    "standalone",
+			RedisTimeout: time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Can't connect to redis: %s", err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+		return storage, server.FastForward, server.Exists
+	})
+}
+
+func TestRedisStorageUnreachableServer(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := newRedisStorage(context.Background(), &settings.Config{
+		RedisURL:     "redis://" + addr,
+		RedisTimeout: 100 * time.Millisecond,
+	})
+	if err == nil {
+		t.Error("Storage creation should fail on unreachable server")
+	}
+}
+
+func TestRedisStorageHonoursCancelledContext(t *testing.T) {
+	server := miniredis.RunT(t)
+	storage, err := newRedisStorage(context.Background(), &settings.Config{RedisURL: "redis://" + server.Addr()})
+	if err != nil {
+		t.Fatalf("Can't connect to redis: %s", err)
+	}
+	defer storage.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := storage.Set(ctx, "key", []byte("value"), 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Set should fail with cancelled context, received: %v", err)
+	}
+}
+
+func TestNewRedisClientModes(t *testing.T) {
+	cases := []struct {
+		name       string
+		url        string
+		mode       string
+		tls        bool
+		shouldFail bool
+	}{
+		{name: "standalone", url: "redis://localhost:6379/1", mode: "standalone"},
+		{name: "standalone tls", url: "rediss://localhost:6379", mode: "standalone", tls: true},
+		{name: "sentinel", url: "redis://localhost:26379?master_name=mymaster&addr=localhost:26380", mode: "sentinel"},
+		{name: "sentinel tls", url: "rediss://localhost:26379?master_name=mymaster", mode: "sentinel", tls: true},
+		{name: "cluster", url: "redis://localhost:7000?addr=localhost:7001", mode: "cluster"},
+		{name: "cluster tls", url: "rediss://localhost:7000", mode: "cluster", tls: true},
+		{name: "empty url", url: "", mode: "standalone", shouldFail: true},
+		{name: "wrong scheme", url: "http://localhost", mode: "standalone", shouldFail: true},
+		{name: "unknown mode", url: "redis://localhost", mode: "ring", shouldFail: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newRedisClient(tt.url, tt.mode)
+			if (err != nil) != tt.shouldFail {
+				t.Fatalf("Unexpected client creation result, error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			defer client.Close()
+
+			var tlsEnabled bool
+			switch c := client.(type) {
+			case *redis.Client:
+				tlsEnabled = c.Options().TLSConfig != nil
+			case *redis.ClusterClient:
+				tlsEnabled = c.Options().TLSConfig != nil
+			}
+			if tlsEnabled != tt.tls {
+				t.Errorf("Wrong TLS configuration, received: %t expected: %t", tlsEnabled, tt.tls)
+			}
+		})
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// testStorageConformance checks behaviour every Storage implementation should follow,
+// factory returns functions moving backend time forward and checking whether key is kept by backend
+func testStorageConformance(t *testing.T, factory func(t *testing.T) (Storage, func(time.Duration), func(string) bool)) {
+	ctx := context.Background()
+
+	t.Run("missing key", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		if _, err := storage.Get(ctx, "conformance:missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Missing key should return ErrNotFound, received: %v", err)
+		}
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		if err := storage.Set(ctx, "conformance:key", []byte("value"), 0); err != nil {
+			t.Fatalf("Set failed: %s", err)
+		}
+		value, err := storage.Get(ctx, "conformance:key")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+		if !bytes.Equal(value, []byte("value")) {
+			t.Errorf("Wrong stored value\nreceived: %q\nexpected: %q", value, "value")
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		_ = storage.Set(ctx, "conformance:overwrite", []byte("first"), 0)
+		_ = storage.Set(ctx, "conformance:overwrite", []byte("second"), 0)
+		value, err := storage.Get(ctx, "conformance:overwrite")
+		if err != nil {
+			t.Fatalf("Get failed: %s", err)
+		}
+		if !bytes.Equal(value, []byte("second")) {
+			t.Errorf("Value should be overwritten\nreceived: %q\nexpected: %q", value, "second")
+		}
+	})
+
+	t.Run("returned value is a copy", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		input := []byte("value")
+		_ = storage.Set(ctx, "conformance:copy", input, 0)
+		input[0] = 'X'
+		value, _ := storage.Get(ctx, "conformance:copy")
+		value[1] = 'X'
+		value, _ = storage.Get(ctx, "conformance:copy")
+		if !bytes.Equal(value, []byte("value")) {
+			t.Errorf("Stored value should not be shared with caller, received: %q", value)
+		}
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		storage, advance, _ := factory(t)
+		_ = storage.Set(ctx, "conformance:ttl", []byte("value"), time.Hour)
+		advance(59 * time.Minute)
+		if _, err := storage.Get(ctx, "conformance:ttl"); err != nil {
+			t.Errorf("Value should be available before expiration, received: %v", err)
+		}
+		advance(time.Minute)
+		if _, err := storage.Get(ctx, "conformance:ttl"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expired value should return ErrNotFound, received: %v", err)
+		}
+	})
+
+	t.Run("unread expired keys are removed", func(t *testing.T) {
+		storage, advance, stored := factory(t)
+		_ = storage.Set(ctx, "conformance:abandoned", []byte("value"), time.Hour)
+		_ = storage.Set(ctx, "conformance:abandoned:next", []byte("value"), time.Hour)
+		_ = storage.Set(ctx, "conformance:kept", []byte("value"), 0)
+		advance(time.Hour)
+		_ = storage.Set(ctx, "conformance:other", []byte("value"), 0)
+		if stored("conformance:abandoned") || stored("conformance:abandoned:next") {
+			t.Error("Expired key should be removed without reading it")
+		}
+		if !stored("conformance:kept") || !stored("conformance:other") {
+			t.Error("Not expired keys should be kept")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		_ = storage.Set(ctx, "conformance:delete", []byte("value"), 0)
+		if err := storage.Delete(ctx, "conformance:delete"); err != nil {
+			t.Fatalf("Delete failed: %s", err)
+		}
+		if _, err := storage.Get(ctx, "conformance:delete"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Deleted key should return ErrNotFound, received: %v", err)
+		}
+		if err := storage.Delete(ctx, "conformance:delete"); err != nil {
+			t.Errorf("Deleting missing key should succeed, received: %v", err)
+		}
+	})
+
+	t.Run("missing list", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		values, err := storage.List(ctx, "conformance:missing-list")
+		if err != nil || len(values) != 0 {
+			t.Errorf("Missing list should be empty, received: %q, %v", values, err)
+		}
+	})
+
+	t.Run("append with limit", func(t *testing.T) {
+		storage, _, _ := factory(t)
+		var dropped [][]byte
+		for _, value := range []string{"first", "second", "third"} {
+			values, err := storage.Append(ctx, "conformance:list", []byte(value), 2)
+			if err != nil {
+				t.Fatalf("Append failed: %s", err)
+			}
+			dropped = append(dropped, values...)
+		}
+		if diff := cmp.Diff(dropped, [][]byte{[]byte("first")}); diff != "" {
+			t.Errorf("Trimmed values should be returned, diff: %s", diff)
+		}
+		_, _ = storage.Append(ctx, "conformance:unlimited", []byte("first"), 0)
+		_, _ = storage.Append(ctx, "conformance:unlimited", []byte("second"), 0)
+
+		for key, expected := range map[string][][]byte{
+			"conformance:list":      {[]byte("second"), []byte("third")},
+			"conformance:unlimited": {[]byte("first"), []byte("second")},
+		} {
+			values, err := storage.List(ctx, key)
+			if err != nil {
+				t.Fatalf("List failed: %s", err)
+			}
+			if diff := cmp.Diff(values, expected); diff != "" {
+				t.Errorf("Wrong %s values, diff: %s", key, diff)
+			}
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TeamsNotifier posts build report to Microsoft Teams incoming webhook connector
+type TeamsNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewTeamsNotifier creates notifier posting to Teams connector URL with client
+func NewTeamsNotifier(webhookURL string, client *http.Client) *TeamsNotifier {
+	return &TeamsNotifier{webhookURL: webhookURL, client: client}
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsSection struct {
+	ActivityTitle string       `json:"activityTitle,omitempty"`
+	Text          string       `json:"text,omitempty"`
+	Facts         []*teamsFact `json:"facts,omitempty"`
+}
+
+type teamsMessageCard struct {
+	Type       string          `json:"@type"`
+	Context    string          `json:"@context"`
+	Summary    string          `json:"summary"`
+	ThemeColor string          `json:"themeColor"`
+	Title      string          `json:"title"`
+	Sections   []*teamsSection `json:"sections"`
+}
+
+// Name returns notifier name
+func (t *TeamsNotifier) Name() string {
+	return "teams"
+}
+
+// Notify posts report as a connector message card, reports without processed issues are skipped
+func (t *TeamsNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	if len(report.Response.Success) == 0 && len(report.Response.Failures) == 0 {
+		return ErrNothingToNotify
+	}
+	body, err := json.Marshal(teamsReportCard(report))
+	if err != nil {
+		return err
+	}
+	if err = postJSON(ctx, t.client, t.webhookURL, body, nil); err != nil {
+		return fmt.Errorf("TeamsNotifier: %w", err)
+	}
+	return nil
+}
+
+func teamsReportCard(report *BuildReport) *teamsMessageCard {
+	resp := report.Response
+	color := "2EB886"
+	if len(resp.Failures) != 0 {
+		color = "D00000"
+	}
+	card := &teamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    fmt.Sprintf("Build %d: %d stamped, %d failed", report.BuildNumber, len(resp.Success), len(resp.Failures)),
+		ThemeColor: color,
+		Title:      report.Subject(),
+		Sections: []*teamsSection{
+			{Facts: []*teamsFact{
+				{"Build number", fmt.Sprintf("%d", report.BuildNumber)},
+				{"Version", report.Version},
+			}},
+		},
+	}
+
+	for _, section := range report.Sections() {
+		links := make([]string, 0, len(section.IDs))
+		for _, id := range section.IDs {
+			links = append(links, fmt.Sprintf("[#%d](%s)", id, report.IssueURL(id)))
+		}
+		card.Sections = append(card.Sections, &teamsSection{
+			ActivityTitle: fmt.Sprintf("%s (%d)", section.Title, len(section.IDs)),
+			Text:          strings.Join(links, "<br>"),
+		})
+	}
+	return card
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTeamsNotifierPostsMessageCard(t *testing.T) {
+	received := new(teamsMessageCard)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(received)
+	}))
+	defer server.Close()
+
+	report := &BuildReport{
+		Response:    &HookResponse{Success: []int{1}, Failures: []int{2}},
+		RedmineHost: "https://redmine.org",
+		BuildNumber: 12,
+		Version:     "v2",
+	}
+	if err := NewTeamsNotifier(server.URL, http.DefaultClient).Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+
+	expected := &teamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    "Build 12: 1 stamped, 1 failed",
+		ThemeColor: "D00000",
+		Title:      "Redmine Hooks Results",
+		Sections: []*teamsSection{
+			{Facts: []*teamsFact{{"Build number", "12"}, {"Version", "v2"}}},
+			{ActivityTitle: "Success (1)", Text: "[#1](https://redmine.org/issues/1)"},
+			{ActivityTitle: "Failures (1)", Text: "[#2](https://redmine.org/issues/2)"},
+		},
+	}
+	if diff := cmp.Diff(received, expected); diff != "" {
+		t.Errorf("Wrong Teams message card, diff: %s", diff)
+	}
+}
+
+func TestNotifiersSkipEmptyReports(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	notifiers := []Notifier{
+		NewSlackNotifier(server.URL, http.DefaultClient),
+		NewTeamsNotifier(server.URL, http.DefaultClient),
+		NewWebhookNotifier(server.URL, nil, "secret", http.DefaultClient),
+	}
+	for _, notifier := range notifiers {
+		report := &BuildReport{Response: NewResponse("")}
+		if err := notifier.Notify(context.Background(), report); !errors.Is(err, ErrNothingToNotify) {
+			t.Errorf("%s notifier should skip report without processed issues, received: %v", notifier.Name(), err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Skipped reports shouldn't be posted, received: %d requests", requests)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+// UserDirectory resolves Redmine users emails and caches them for the process lifetime
+type UserDirectory struct {
+	settings *settings.Config
+	client   *http.Client
+	mu       sync.Mutex
+	emails   map[int]string
+}
+
+// NewUserDirectory creates directory fetching users from Redmine with client
+func NewUserDirectory(settings *settings.Config, client *http.Client) *UserDirectory {
+	return &UserDirectory{settings: settings, client: client, emails: make(map[int]string)}
+}
+
+// Email returns Redmine user email
+func (d *UserDirectory) Email(ctx context.Context, userID int) (string, error) {
+	d.mu.Lock()
+	email, ok := d.emails[userID]
+	d.mu.Unlock()
+	if ok {
+		return email, nil
+	}
+
+	u, err := user(ctx, d.client, d.settings, userID)
+	if err != nil {
+		return "", fmt.Errorf("UserDirectory: can't fetch user #%d: %w", userID, err)
+	}
+	if u.Mail == "" {
+		return "", fmt.Errorf("UserDirectory: user #%d has no visible email", userID)
+	}
+
+	d.mu.Lock()
+	d.emails[userID] = u.Mail
+	d.mu.Unlock()
+	return u.Mail, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+func TestUserDirectoryCachesEmails(t *testing.T) {
+	requests := 0
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/users/7.json":
+			_, _ = w.Write([]byte(`{"user":{"id":7,"login":"jdoe","mail":"jdoe@example.com"}}`))
+		case "/users/8.json":
+			_, _ = w.Write([]byte(`{"user":{"id":8,"login":"hidden"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer redmine.Close()
+
+	directory := NewUserDirectory(&settings.Config{Host: redmine.URL}, http.DefaultClient)
+	for i := 0; i < 2; i++ {
+		email, err := directory.Email(context.Background(), 7)
+		if err != nil {
+			t.Fatalf("Email failed: %s", err)
+		}
+		if email != "jdoe@example.com" {
+			t.Errorf("Wrong email: %s", email)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("User email should be cached, received %d requests", requests)
+	}
+
+	for _, userID := range []int{8, 9} {
+		if _, err := directory.Email(context.Background(), userID); err == nil {
+			t.Errorf("Email lookup for user #%d should fail", userID)
+		}
+	}
+}
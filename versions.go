@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+// VersionManager creates or reuses Redmine Versions named after finished builds
+type VersionManager struct {
+	settings *settings.Config
+	client   *http.Client
+	name     *BuildTemplate
+	// mu prevents concurrent builds from creating the same version twice
+	mu sync.Mutex
+}
+
+// NewVersionManager parses version name template,
+// e.g. `{{.Tag}} (build {{.BuildNumber}})`
+func NewVersionManager(settings *settings.Config, client *http.Client, nameTemplate string) (*VersionManager, error) {
+	name, err := NewBuildTemplate("version name", nameTemplate, settings.BitriseAPIToken != "")
+	if err != nil {
+		return nil, fmt.Errorf("NewVersionManager: %w", err)
+	}
+	return &VersionManager{settings: settings, client: client, name: name}, nil
+}
+
+// Ensure returns project version named for the build, the version is created when missing
+func (v *VersionManager) Ensure(ctx context.Context, project string, payload *HookPayload) (*Version, error) {
+	name, err := v.name.Render(project, payload)
+	if err != nil {
+		return nil, fmt.Errorf("Ensure: can't render version name: %w", err)
+	}
+	version, err := v.ensure(ctx, project, name)
+	if err != nil {
+		return nil, fmt.Errorf("Ensure: %w", err)
+	}
+	return version, nil
+}
+
+// EnsureForIssues returns the project version and target version IDs keyed by issue project ID.
+// Redmine doesn't share versions between projects by default, so every project of the
+// issues gets its own version with the same name. Issues without project use the project version.
+// Projects failed to prepare are missing in the IDs and the error is returned along with the rest.
+func (v *VersionManager) EnsureForIssues(ctx context.Context, project string, issues []*Issue, payload *HookPayload) (*Version, map[int]int, error) {
+	name, err := v.name.Render(project, payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("EnsureForIssues: can't render version name: %w", err)
+	}
+	primary, err := v.ensure(ctx, project, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("EnsureForIssues: %w", err)
+	}
+
+	ids := map[int]int{0: primary.ID}
+	var projects []int
+	for _, issue := range issues {
+		if _, ok := ids[issue.Project.ID]; !ok {
+			ids[issue.Project.ID] = 0
+			projects = append(projects, issue.Project.ID)
+		}
+	}
+	sort.Ints(projects)
+	var errs []error
+	for _, projectID := range projects {
+		version, err := v.ensure(ctx, strconv.Itoa(projectID), name)
+		if err != nil {
+			delete(ids, projectID)
+			errs = append(errs, fmt.Errorf("project %d: %w", projectID, err))
+			continue
+		}
+		ids[projectID] = version.ID
+	}
+	if len(errs) != 0 {
+		return primary, ids, fmt.Errorf("EnsureForIssues: %w", errors.Join(errs...))
+	}
+	return primary, ids, nil
+}
+
+// ensure finds version by name in the Redmine project identifier or ID, the version is created when missing
+func (v *VersionManager) ensure(ctx context.Context, project, name string) (*Version, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	existing, err := versions(ctx, v.client, v.settings, project)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch project versions: %w", err)
+	}
+	for _, version := range existing {
+		if version.Name == name {
+			return version, nil
+		}
+	}
+	version, err := createVersion(ctx, v.client, v.settings, project, name)
+	if err != nil {
+		return nil, fmt.Errorf("can't create version %q: %w", name, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newVersionsServer(t *testing.T, created *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/ios/versions.json" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var body struct {
+				Version struct {
+					Name string `json:"name"`
+				} `json:"version"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Can't decode version body: %s", err)
+			}
+			*created = append(*created, body.Version.Name)
+			_, _ = w.Write([]byte(`{"version":{"id":20,"name":"` + body.Version.Name + `"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"versions":[{"id":10,"name":"1.3.0 (build 400)"}]}`))
+	}))
+}
+
+func TestVersionManagerEnsure(t *testing.T) {
+	var created []string
+	redmine := newVersionsServer(t, &created)
+	defer redmine.Close()
+
+	manager, err := NewVersionManager(&settings.Config{Host: redmine.URL}, http.DefaultClient, "{{.Tag}} (build {{.BuildNumber}})")
+	if err != nil {
+		t.Fatalf("Can't create version manager: %s", err)
+	}
+
+	cases := []struct {
+		tag      string
+		number   int
+		expected *Version
+	}{
+		{"1.3.0", 400, &Version{ID: 10, Name: "1.3.0 (build 400)"}},
+		{"1.4.0", 512, &Version{ID: 20, Name: "1.4.0 (build 512)"}},
+	}
+	for _, tc := range cases {
+		payload := &HookPayload{BuildNumber: tc.number}
+		payload.Git.Tag = tc.tag
+		version, err := manager.Ensure(context.Background(), "ios", payload)
+		if err != nil {
+			t.Fatalf("Ensure failed: %s", err)
+		}
+		if diff := cmp.Diff(version, tc.expected); diff != "" {
+			t.Errorf("Wrong version, diff: %s", diff)
+		}
+	}
+	if diff := cmp.Diff(created, []string{"1.4.0 (build 512)"}); diff != "" {
+		t.Errorf("Only missing version should be created, diff: %s", diff)
+	}
+}
+
+func TestVersionManagerWrongTemplate(t *testing.T) {
+	if _, err := NewVersionManager(&settings.Config{}, http.DefaultClient, "{{.Tag"); err == nil {
+		t.Error("Broken template should fail")
+	}
+
+	manager, _ := NewVersionManager(&settings.Config{}, http.DefaultClient, "{{.Tag}}")
+	if _, err := manager.Ensure(context.Background(), "ios", &HookPayload{}); err == nil {
+		t.Error("Empty version name should fail")
+	}
+}
+
+func TestStamperFinishedEventSetsFixedVersion(t *testing.T) {
+	var created []string
+	versionsServer := newVersionsServer(t, &created)
+	defer versionsServer.Close()
+
+	var mu sync.Mutex
+	var fixedVersions []int
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/projects/ios/versions.json":
+			versionsServer.Config.Handler.ServeHTTP(w, r)
+		case r.Method == http.MethodPut:
+			var body struct {
+				Issue struct {
+					FixedVersionID int `json:"fixed_version_id"`
+				} `json:"issue"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			fixedVersions = append(fixedVersions, body.Issue.FixedVersionID)
+			mu.Unlock()
+		default:
+			_, _ = w.Write([]byte(`{"issues":[{"id":2}]}`))
+		}
+	}))
+	defer redmine.Close()
+
+	config := &settings.Config{Host: redmine.URL, CacheTTL: time.Hour}
+	stamper := NewStamper(config, http.DefaultClient, newMemoryStorage())
+	manager, _ := NewVersionManager(config, http.DefaultClient, "{{.Tag}} (build {{.BuildNumber}})")
+	stamper.SetVersions(manager)
+
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_slug":"slug", "build_number":512, "git":{"tag":"1.4.0"}}`))
+	req.Header.Set("REDMINE_PROJECT", "ios")
+	req.Header.Set("Bitrise-Event-Type", "build/finished")
+	rw := httptest.NewRecorder()
+	stamper.ServeHTTP(rw, req)
+
+	resp := new(HookResponse)
+	if err := json.NewDecoder(rw.Body).Decode(resp); err != nil {
+		t.Fatalf("Can't decode response: %s", err)
+	}
+	if diff := cmp.Diff(resp.FixedVersion, &Version{ID: 20, Name: "1.4.0 (build 512)"}); diff != "" {
+		t.Errorf("Wrong fixed version in response, diff: %s", diff)
+	}
+	if diff := cmp.Diff(fixedVersions, []int{20}); diff != "" {
+		t.Errorf("Stamped issues should be moved to the version, diff: %s", diff)
+	}
+}
+
+func TestStamperFinishedEventSetsFixedVersionPerProject(t *testing.T) {
+	var mu sync.Mutex
+	var created []string
+	fixedVersions := map[int]int{}
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/projects/ios/versions.json" || r.URL.Path == "/projects/1/versions.json":
+			_, _ = w.Write([]byte(`{"versions":[{"id":20,"name":"1.4.0 (build 512)"}]}`))
+		case r.URL.Path == "/projects/2/versions.json" && r.Method == http.MethodPost:
+			created = append(created, r.URL.Path)
+			_, _ = w.Write([]byte(`{"version":{"id":30,"name":"1.4.0 (build 512)"}}`))
+		case r.URL.Path == "/projects/2/versions.json":
+			_, _ = w.Write([]byte(`{"versions":[]}`))
+		case r.Method == http.MethodPut:
+			var body struct {
+				Issue struct {
+					FixedVersionID int `json:"fixed_version_id"`
+				} `json:"issue"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			var id int
+			_, _ = fmt.Sscanf(r.URL.Path, "/issues/%d.json", &id)
+			fixedVersions[id] = body.Issue.FixedVersionID
+		case r.URL.Query().Get("project_id") == "ios":
+			_, _ = w.Write([]byte(`{"issues":[{"id":2,"project":{"id":1}}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"issues":[{"id":3,"project":{"id":2}}]}`))
+		}
+	}))
+	defer redmine.Close()
+
+	config := &settings.Config{Host: redmine.URL, CacheTTL: time.Hour}
+	stamper := NewStamper(config, http.DefaultClient, newMemoryStorage())
+	manager, _ := NewVersionManager(config, http.DefaultClient, "{{.Tag}} (build {{.BuildNumber}})")
+	stamper.SetVersions(manager)
+
+	req, _ := http.NewRequest(http.MethodPost, "", newMockBody(`{"build_triggered_workflow":"internal", "build_status":1, "build_slug":"slug", "build_number":512, "git":{"tag":"1.4.0"}}`))
+	req.Header.Set("REDMINE_PROJECT", "ios,core")
+	req.Header.Set("Bitrise-Event-Type", "build/finished")
+	rw := httptest.NewRecorder()
+	stamper.ServeHTTP(rw, req)
+
+	resp := new(HookResponse)
+	if err := json.NewDecoder(rw.Body).Decode(resp); err != nil {
+		t.Fatalf("Can't decode response: %s", err)
+	}
+	if diff := cmp.Diff(resp.FixedVersion, &Version{ID: 20, Name: "1.4.0 (build 512)"}); diff != "" {
+		t.Errorf("Primary project version should be returned, diff: %s", diff)
+	}
+	if diff := cmp.Diff(fixedVersions, map[int]int{2: 20, 3: 30}); diff != "" {
+		t.Errorf("Issues should be moved to the version of their project, diff: %s", diff)
+	}
+	if diff := cmp.Diff(created, []string{"/projects/2/versions.json"}); diff != "" {
+		t.Errorf("Missing version should be created in the secondary project, diff: %s", diff)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookSignatureHeader keeps HMAC-SHA256 signature of outgoing webhook body
+const WebhookSignatureHeader = "X-Hook-Signature"
+
+// WebhookNotifier posts build report as JSON to an arbitrary endpoint
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	secret  string
+	client  *http.Client
+}
+
+// NewWebhookNotifier creates notifier posting to url with extra headers,
+// body is signed when secret is not empty
+func NewWebhookNotifier(url string, headers map[string]string, secret string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{url: url, headers: headers, secret: secret, client: client}
+}
+
+// WebhookReport represents outgoing webhook JSON payload
+type WebhookReport struct {
+	BuildNumber int             `json:"build_number"`
+	BuildSlug   string          `json:"build_slug,omitempty"`
+	Workflow    string          `json:"workflow,omitempty"`
+	Version     string          `json:"version"`
+	Project     string          `json:"project,omitempty"`
+	RedmineHost string          `json:"redmine_host"`
+	Issues      []*WebhookIssue `json:"issues"`
+	Result      *HookResponse   `json:"result"`
+}
+
+// WebhookIssue represents stamped or failed issue details in webhook payload,
+// subject, tracker and assignee are empty for issues unknown during processing
+type WebhookIssue struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	URL      string `json:"url"`
+	Subject  string `json:"subject,omitempty"`
+	Tracker  string `json:"tracker,omitempty"`
+	Assignee string `json:"assignee,omitempty"`
+}
+
+// Webhook issue statuses
+const (
+	WebhookIssueStamped = "stamped"
+	WebhookIssueFailed  = "failed"
+)
+
+// Name returns notifier name
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts signed report, reports without processed issues are skipped
+func (w *WebhookNotifier) Notify(ctx context.Context, report *BuildReport) error {
+	if len(report.Response.Success) == 0 && len(report.Response.Failures) == 0 {
+		return ErrNothingToNotify
+	}
+	payload := &WebhookReport{
+		BuildNumber: report.BuildNumber,
+		BuildSlug:   report.BuildSlug,
+		Workflow:    report.Workflow,
+		Version:     report.Version,
+		Project:     report.ProjectName(),
+		RedmineHost: report.RedmineHost,
+		Issues:      webhookIssues(report),
+		Result:      report.Response,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(w.headers)+1)
+	for key, value := range w.headers {
+		headers[key] = value
+	}
+	if w.secret != "" {
+		headers[WebhookSignatureHeader] = "sha256=" + signWebhookBody(w.secret, body)
+	}
+	if err = postJSON(ctx, w.client, w.url, body, headers); err != nil {
+		return fmt.Errorf("WebhookNotifier: %w", err)
+	}
+	return nil
+}
+
+func webhookIssues(report *BuildReport) []*WebhookIssue {
+	known := make(map[int]*Issue, len(report.Issues))
+	for _, issue := range report.Issues {
+		known[issue.ID] = issue
+	}
+	issues := make([]*WebhookIssue, 0, len(report.Response.Success)+len(report.Response.Failures))
+	add := func(ids []int, status string) {
+		for _, id := range ids {
+			item := &WebhookIssue{ID: id, Status: status, URL: report.IssueURL(id)}
+			if issue, ok := known[id]; ok {
+				item.Subject = issue.Subject
+				item.Tracker = issue.Tracker.Name
+				item.Assignee = issue.AssignedTo.Name
+			}
+			issues = append(issues, item)
+		}
+	}
+	add(report.Response.Success, WebhookIssueStamped)
+	add(report.Response.Failures, WebhookIssueFailed)
+	return issues
+}
+
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
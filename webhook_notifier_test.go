@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWebhookNotifierSignsPayload(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	report := &BuildReport{
+		Response:    &HookResponse{Success: []int{1}, Failures: []int{}},
+		RedmineHost: "https://redmine.org",
+		BuildNumber: 12,
+		Version:     "v2",
+	}
+	notifier := NewWebhookNotifier(server.URL, map[string]string{"X-Token": "abc"}, "secret", http.DefaultClient)
+	if err := notifier.Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+
+	if header.Get("X-Token") != "abc" {
+		t.Errorf("Custom header should be sent, received: %q", header.Get("X-Token"))
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature := header.Get(WebhookSignatureHeader); signature != expected {
+		t.Errorf("Wrong signature\nreceived: %s\nexpected: %s", signature, expected)
+	}
+
+	payload := new(WebhookReport)
+	if err := json.Unmarshal(body, payload); err != nil {
+		t.Fatalf("Can't decode payload: %s", err)
+	}
+	if payload.BuildNumber != 12 || len(payload.Result.Success) != 1 {
+		t.Errorf("Wrong webhook payload: %+v", payload)
+	}
+}
+
+func TestWebhookNotifierWithoutSecret(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+
+	report := &BuildReport{Response: &HookResponse{Success: []int{1}}}
+	if err := NewWebhookNotifier(server.URL, nil, "", http.DefaultClient).Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+	if _, ok := header[WebhookSignatureHeader]; ok {
+		t.Error("Signature header should not be sent without secret")
+	}
+}
+
+func TestWebhookNotifierIssueDetails(t *testing.T) {
+	payload := new(WebhookReport)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(payload)
+	}))
+	defer server.Close()
+
+	issue := &Issue{ID: 1, Subject: "Crash on start"}
+	issue.Project.Name = "iOS App"
+	issue.Tracker.Name = "Bug"
+	issue.AssignedTo.Name = "John Doe"
+	report := &BuildReport{
+		Response:    &HookResponse{Success: []int{1}, Failures: []int{2}},
+		RedmineHost: "https://redmine.org",
+		Project:     "ios",
+		BuildNumber: 12,
+		BuildSlug:   "slug",
+		Workflow:    "internal",
+		Issues:      []*Issue{issue},
+		Version:     "v2",
+	}
+	if err := NewWebhookNotifier(server.URL, nil, "", http.DefaultClient).Notify(context.Background(), report); err != nil {
+		t.Fatalf("Notify failed: %s", err)
+	}
+
+	expected := &WebhookReport{
+		BuildNumber: 12,
+		BuildSlug:   "slug",
+		Workflow:    "internal",
+		Version:     "v2",
+		Project:     "iOS App",
+		RedmineHost: "https://redmine.org",
+		Issues: []*WebhookIssue{
+			{ID: 1, Status: WebhookIssueStamped, URL: "https://redmine.org/issues/1", Subject: "Crash on start", Tracker: "Bug", Assignee: "John Doe"},
+			{ID: 2, Status: WebhookIssueFailed, URL: "https://redmine.org/issues/2"},
+		},
+		Result: report.Response,
+	}
+	if diff := cmp.Diff(payload, expected); diff != "" {
+		t.Errorf("Wrong webhook payload, diff: %s", diff)
+	}
+}
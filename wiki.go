@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+)
+
+// WikiPublisher writes build release notes into Redmine project wiki
+type WikiPublisher struct {
+	settings    *settings.Config
+	client      *http.Client
+	title       *BuildTemplate
+	text        *texttemplate.Template
+	appendPages bool
+	now         func() time.Time
+	// mu serializes read-modify-write of appended pages
+	mu sync.Mutex
+}
+
+// wikiPageView is a data passed to wiki page template
+type wikiPageView struct {
+	*ReleaseNotes
+	BuildSlug  string
+	Workflow   string
+	Branch     string
+	Tag        string
+	Version    *Version
+	Build      *BitriseBuild
+	FinishedAt time.Time
+}
+
+// NewWikiPublisher parses page title template, e.g. `Build_{{.BuildNumber}}`.
+// With appendPages build section is added to the end of existing page instead of replacing it,
+// so a title like `Release_{{.Tag}}` collects all builds of the version.
+// Rendered titles are normalized like Redmine does, e.g. dots of the tag are removed.
+// Page text is rendered from the embedded Markdown template unless settings point to another file
+func NewWikiPublisher(settings *settings.Config, client *http.Client, titleTemplate string, appendPages bool) (*WikiPublisher, error) {
+	title, err := NewBuildTemplate("wiki page title", titleTemplate, settings.BitriseAPIToken != "")
+	if err != nil {
+		return nil, fmt.Errorf("NewWikiPublisher: %w", err)
+	}
+	source, err := readTemplate(settings.WikiTextTemplate, "templates/wiki_page.md.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("NewWikiPublisher: %w", err)
+	}
+	text, err := texttemplate.New("wiki page").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("NewWikiPublisher: wrong page template: %w", err)
+	}
+	return &WikiPublisher{settings: settings, client: client, title: title, text: text, appendPages: appendPages, now: time.Now}, nil
+}
+
+// Publish creates or updates the build wiki page and returns its title
+func (w *WikiPublisher) Publish(ctx context.Context, project string, payload *HookPayload, notes *ReleaseNotes, version *Version) (string, error) {
+	title, err := w.title.Render(project, payload)
+	if err != nil {
+		return "", fmt.Errorf("Publish: can't render page title: %w", err)
+	}
+	title = wikiTitle(title)
+
+	var buffer bytes.Buffer
+	err = w.text.Execute(&buffer, &wikiPageView{
+		ReleaseNotes: notes,
+		BuildSlug:    payload.BuildSlug,
+		Workflow:     payload.BuildTriggeredWorkflow,
+		Branch:       payload.Git.SrcBranch,
+		Tag:          payload.Git.Tag,
+		Version:      version,
+		Build:        payload.Details,
+		FinishedAt:   w.now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Publish: can't render page text: %w", err)
+	}
+	page := &WikiPage{Text: buffer.String(), Comments: fmt.Sprintf("Build %d", payload.BuildNumber)}
+
+	if w.appendPages {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		existing, err := wikiPage(ctx, w.client, w.settings, project, title)
+		switch {
+		case err == nil:
+			page.Text = strings.TrimRight(existing.Text, "\n") + "\n\n" + page.Text
+		case !errors.Is(err, errRedmineNotFound):
+			return "", fmt.Errorf("Publish: can't fetch wiki page %s: %w", title, err)
+		}
+	}
+	if err = saveWikiPage(ctx, w.client, w.settings, project, title, page); err != nil {
+		return "", fmt.Errorf("Publish: can't save wiki page %s: %w", title, err)
+	}
+	return title, nil
+}
+
+// wikiTitle normalizes page title the way Redmine Wiki.titleize does: whitespace is replaced
+// with underscores, characters Redmine rejects are removed and the first letter is upcased,
+// e.g. `Release v1.4.0` becomes `Release_v140`
+func wikiTitle(title string) string {
+	title = strings.Join(strings.Fields(title), "_")
+	title = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(",./?;|:", r) {
+			return -1
+		}
+		return r
+	}, title)
+	for i, r := range title {
+		return string(unicode.ToUpper(r)) + title[i+utf8.RuneLen(r):]
+	}
+	return title
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alphatroya/ci-redmine-bindings/settings"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newWikiServer(t *testing.T, pages map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/projects/ios/wiki/"), ".json")
+		// Redmine wiki route doesn't match titles with these characters
+		if strings.ContainsAny(title, ",./?;|:") {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			text, ok := pages[title]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]*WikiPage{"wiki_page": {Title: title, Text: text}})
+		case http.MethodPut:
+			var body struct {
+				WikiPage *WikiPage `json:"wiki_page"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Can't decode wiki page body: %s", err)
+			}
+			pages[title] = body.WikiPage.Text
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestWikiPublisherPublish(t *testing.T) {
+	pages := map[string]string{"Build_12": "outdated"}
+	redmine := newWikiServer(t, pages)
+	defer redmine.Close()
+
+	wiki, err := NewWikiPublisher(&settings.Config{Host: redmine.URL}, http.DefaultClient, "Build {{.BuildNumber}}", false)
+	if err != nil {
+		t.Fatalf("Can't create wiki publisher: %s", err)
+	}
+	wiki.now = func() time.Time { return time.Date(2022, 10, 5, 10, 0, 0, 0, time.UTC) }
+
+	payload := &HookPayload{BuildSlug: "slug", BuildNumber: 12, BuildTriggeredWorkflow: "internal"}
+	payload.Git.Tag = "1.4.0"
+	issues := []*Issue{{ID: 3, Subject: "Crash on start"}}
+	issues[0].Tracker.ID, issues[0].Tracker.Name = 1, "Bug"
+	notes := NewReleaseNotes("ios", 12, "", issues, []int{3})
+
+	title, err := wiki.Publish(context.Background(), "ios", payload, notes, &Version{ID: 20, Name: "1.4.0 (build 12)"})
+	if err != nil {
+		t.Fatalf("Publish failed: %s", err)
+	}
+	if title != "Build_12" {
+		t.Errorf("Wrong page title: %s", title)
+	}
+	expected := strings.Join([]string{
+		"## ios build 12",
+		"",
+		"* Finished: 2022-10-05 10:00 UTC",
+		"* Bitrise build: slug",
+		"* Workflow: internal",
+		"* Tag: 1.4.0",
+		"* Version: 1.4.0 (build 12)",
+		"",
+		"### Bug",
+		"",
+		"* Crash on start (#3)",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(pages["Build_12"], expected); diff != "" {
+		t.Errorf("Page should be replaced, diff: %s", diff)
+	}
+}
+
+func TestWikiPublisherAppendsBuilds(t *testing.T) {
+	pages := map[string]string{}
+	redmine := newWikiServer(t, pages)
+	defer redmine.Close()
+
+	wiki, _ := NewWikiPublisher(&settings.Config{Host: redmine.URL}, http.DefaultClient, "Release_{{.Tag}}", true)
+	for _, number := range []int{12, 13} {
+		payload := &HookPayload{BuildNumber: number}
+		payload.Git.Tag = "v1.4.0"
+		title, err := wiki.Publish(context.Background(), "ios", payload, NewReleaseNotes("ios", number, "", nil, []int{number}), nil)
+		if err != nil {
+			t.Fatalf("Publish failed: %s", err)
+		}
+		if title != "Release_v140" {
+			t.Errorf("Dotted tag should be removed from title like Redmine does, received: %s", title)
+		}
+	}
+
+	text := pages["Release_v140"]
+	first, second := strings.Index(text, "## ios build 12"), strings.Index(text, "## ios build 13")
+	if first != 0 || second <= first {
+		t.Errorf("Builds should be appended to the page, received: %s", text)
+	}
+}
+
+func TestWikiPublisherCustomTextTemplate(t *testing.T) {
+	pages := map[string]string{}
+	redmine := newWikiServer(t, pages)
+	defer redmine.Close()
+
+	path := filepath.Join(t.TempDir(), "wiki.textile")
+	if err := os.WriteFile(path, []byte("h2. {{.Project}} build {{.BuildNumber}}"), 0o600); err != nil {
+		t.Fatalf("Can't write template: %s", err)
+	}
+	config := &settings.Config{Host: redmine.URL, WikiTextTemplate: path}
+	wiki, err := NewWikiPublisher(config, http.DefaultClient, "Build_{{.BuildNumber}}", false)
+	if err != nil {
+		t.Fatalf("Can't create wiki publisher: %s", err)
+	}
+	if _, err = wiki.Publish(context.Background(), "ios", &HookPayload{BuildNumber: 12}, NewReleaseNotes("ios", 12, "", nil, nil), nil); err != nil {
+		t.Fatalf("Publish failed: %s", err)
+	}
+	if diff := cmp.Diff(pages["Build_12"], "h2. ios build 12"); diff != "" {
+		t.Errorf("Page should be rendered with custom template, diff: %s", diff)
+	}
+
+	_ = os.WriteFile(path+".broken", []byte("{{.Project"), 0o600)
+	for _, config := range []*settings.Config{{WikiTextTemplate: path + ".missing"}, {WikiTextTemplate: path + ".broken"}} {
+		if _, err := NewWikiPublisher(config, http.DefaultClient, "Build_{{.BuildNumber}}", false); err == nil {
+			t.Errorf("Wrong page template %s should fail", config.WikiTextTemplate)
+		}
+	}
+}
+
+func TestWikiPublisherFailures(t *testing.T) {
+	redmine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer redmine.Close()
+
+	if _, err := NewWikiPublisher(&settings.Config{}, http.DefaultClient, "{{.Tag", false); err == nil {
+		t.Error("Broken title template should fail")
+	}
+	for _, appendPages := range []bool{false, true} {
+		wiki, _ := NewWikiPublisher(&settings.Config{Host: redmine.URL}, http.DefaultClient, "Build_{{.BuildNumber}}", appendPages)
+		if _, err := wiki.Publish(context.Background(), "ios", &HookPayload{BuildNumber: 1}, NewReleaseNotes("ios", 1, "", nil, nil), nil); err == nil {
+			t.Errorf("Publish should fail on Redmine errors, append: %t", appendPages)
+		}
+	}
+}
+
+func TestWikiTitle(t *testing.T) {
+	cases := map[string]string{
+		"Build 12":                "Build_12",
+		"Release_v1.4.0":          "Release_v140",
+		"release  1.4, ios/core?": "Release_14_ioscore",
+		"":                        "",
+	}
+	for title, expected := range cases {
+		if received := wikiTitle(title); received != expected {
+			t.Errorf("Wrong title for %q\nreceived: %s\nexpected: %s", title, received, expected)
+		}
+	}
+}